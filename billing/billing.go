@@ -0,0 +1,209 @@
+package billing
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/timeglass/glass/config"
+)
+
+var Scopes = []string{"commit", "day", "report"}
+var Modes = []string{"up", "down", "nearest"}
+
+// something that is billed, e.g: a commit or
+// an author's contribution to a commit
+type Item struct {
+	Date   time.Time
+	Time   time.Duration
+	Billed time.Duration
+}
+
+// applies the billing rules of a configuration
+type Biller struct {
+	conf *config.Billing
+}
+
+// creates a biller for the given configuration, which
+// may be nil in which case time is billed as measured
+func New(conf *config.Billing) (*Biller, error) {
+	b := &Biller{conf}
+	if conf == nil || conf.Rounding == nil {
+		return b, nil
+	}
+
+	if !contains(Scopes, b.scope()) {
+		return nil, fmt.Errorf("Unknown rounding scope '%s', expected one of: commit, day or report", conf.Rounding.Scope)
+	}
+
+	if !contains(Modes, b.mode()) {
+		return nil, fmt.Errorf("Unknown rounding mode '%s', expected one of: up, down or nearest", conf.Rounding.Mode)
+	}
+
+	if conf.Rounding.Unit < 0 {
+		return nil, fmt.Errorf("Rounding unit must not be negative, got: %s", conf.Rounding.Unit)
+	}
+
+	return b, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *Biller) scope() string {
+	if b.conf.Rounding.Scope == "" {
+		return "commit"
+	}
+
+	return b.conf.Rounding.Scope
+}
+
+func (b *Biller) mode() string {
+	if b.conf.Rounding.Mode == "" {
+		return "nearest"
+	}
+
+	return b.conf.Rounding.Mode
+}
+
+// wether any billing rules are configured
+func (b *Biller) Enabled() bool {
+	return b.conf != nil
+}
+
+// wether an hourly rate is configured
+func (b *Biller) HasRate() bool {
+	return b.conf != nil && b.conf.Rate > 0
+}
+
+// sets the billed time of each item: items are grouped by the rounding
+// scope and the total time of each group is rounded and raised to the
+// minimum charge. The difference is then attributed to the items of the
+// group in order, without ever making the billed time of an item negative
+func (b *Biller) Apply(items []*Item) error {
+	for _, it := range items {
+		it.Billed = it.Time
+	}
+
+	if b.conf == nil {
+		return nil
+	}
+
+	scope, mode, unit := "commit", "nearest", time.Duration(0)
+	if b.conf.Rounding != nil {
+		scope, mode, unit = b.scope(), b.mode(), time.Duration(b.conf.Rounding.Unit)
+	}
+
+	groups := [][]*Item{}
+	days := map[string]int{}
+	for _, it := range items {
+		switch scope {
+		case "commit":
+			groups = append(groups, []*Item{it})
+		case "day":
+			key := it.Date.Format("2006-01-02")
+			idx, ok := days[key]
+			if !ok {
+				idx = len(groups)
+				days[key] = idx
+				groups = append(groups, []*Item{})
+			}
+
+			groups[idx] = append(groups[idx], it)
+		case "report":
+			if len(groups) == 0 {
+				groups = append(groups, []*Item{})
+			}
+
+			groups[0] = append(groups[0], it)
+		}
+	}
+
+	for _, group := range groups {
+		var total time.Duration
+		for _, it := range group {
+			total += it.Time
+		}
+
+		if total <= 0 {
+			continue
+		}
+
+		billed, err := Round(total, unit, mode)
+		if err != nil {
+			return err
+		}
+
+		if min := time.Duration(b.conf.Minimum); billed < min {
+			billed = min
+		}
+
+		diff := billed - total
+		for _, it := range group {
+			it.Billed += diff
+			diff = 0
+			if it.Billed < 0 {
+				diff = it.Billed
+				it.Billed = 0
+			}
+		}
+	}
+
+	return nil
+}
+
+// the amount of money for the given (billed) time, rounded to cents
+func (b *Biller) Amount(d time.Duration) float64 {
+	if !b.HasRate() {
+		return 0
+	}
+
+	return math.Floor(d.Hours()*b.conf.Rate*100+0.5) / 100
+}
+
+// the currency that amounts are expressed in
+func (b *Biller) Currency() string {
+	if b.conf == nil {
+		return ""
+	}
+
+	return b.conf.Currency
+}
+
+// formats the amount for the given time, e.g: "142.50 EUR"
+func (b *Biller) Money(d time.Duration) string {
+	if b.Currency() == "" {
+		return fmt.Sprintf("%.2f", b.Amount(d))
+	}
+
+	return fmt.Sprintf("%.2f %s", b.Amount(d), b.Currency())
+}
+
+// rounds the duration to a multiple of unit, mode
+// can be either 'up', 'down' or 'nearest'
+func Round(d, unit time.Duration, mode string) (time.Duration, error) {
+	if unit <= 0 {
+		return d, nil
+	}
+
+	n := float64(d) / float64(unit)
+	switch mode {
+	case "up":
+		n = math.Ceil(n)
+	case "down":
+		n = math.Floor(n)
+	case "nearest", "":
+		n = math.Floor(n + 0.5)
+	default:
+		return d, fmt.Errorf("Unknown rounding mode '%s', expected one of: up, down or nearest", mode)
+	}
+
+	return time.Duration(n) * unit, nil
+}
@@ -0,0 +1,114 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timeglass/glass/config"
+)
+
+func items(times ...time.Duration) []*Item {
+	day := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	list := []*Item{}
+	for i, t := range times {
+		//two items per day
+		list = append(list, &Item{Date: day.Add(time.Duration(i/2) * 24 * time.Hour), Time: t})
+	}
+
+	return list
+}
+
+func billed(list []*Item) []time.Duration {
+	res := []time.Duration{}
+	for _, it := range list {
+		res = append(res, it.Billed)
+	}
+
+	return res
+}
+
+func TestRound(t *testing.T) {
+	q := 15 * time.Minute
+	for _, c := range []struct {
+		d    time.Duration
+		mode string
+		exp  time.Duration
+	}{
+		{20 * time.Minute, "up", 30 * time.Minute},
+		{20 * time.Minute, "down", q},
+		{20 * time.Minute, "nearest", q},
+		{23 * time.Minute, "nearest", 30 * time.Minute},
+		{30 * time.Minute, "up", 30 * time.Minute},
+	} {
+		res, err := Round(c.d, q, c.mode)
+		assert.NoError(t, err)
+		assert.Equal(t, c.exp, res)
+	}
+
+	_, err := Round(time.Minute, q, "sideways")
+	assert.Error(t, err)
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := New(&config.Billing{Rounding: &config.Rounding{Scope: "week"}})
+	assert.Error(t, err)
+
+	_, err = New(&config.Billing{Rounding: &config.Rounding{Mode: "sideways"}})
+	assert.Error(t, err)
+}
+
+func TestApplyWithoutRules(t *testing.T) {
+	b, err := New(nil)
+	assert.NoError(t, err)
+	assert.False(t, b.Enabled())
+
+	list := items(7*time.Minute, 0)
+	assert.NoError(t, b.Apply(list))
+	assert.Equal(t, []time.Duration{7 * time.Minute, 0}, billed(list))
+}
+
+func TestApplyCommitScope(t *testing.T) {
+	b, err := New(&config.Billing{
+		Rounding: &config.Rounding{Scope: "commit", Mode: "up", Unit: config.Duration(15 * time.Minute)},
+		Minimum:  config.Duration(30 * time.Minute),
+	})
+	assert.NoError(t, err)
+
+	list := items(7*time.Minute, 40*time.Minute, 0)
+	assert.NoError(t, b.Apply(list))
+	assert.Equal(t, []time.Duration{30 * time.Minute, 45 * time.Minute, 0}, billed(list))
+}
+
+func TestApplyDayScope(t *testing.T) {
+	b, err := New(&config.Billing{
+		Rounding: &config.Rounding{Scope: "day", Mode: "down", Unit: config.Duration(15 * time.Minute)},
+	})
+	assert.NoError(t, err)
+
+	//the first day rounds 25m down to 15m, the difference is
+	//taken from the first item until it reaches zero
+	list := items(5*time.Minute, 20*time.Minute, 20*time.Minute, 20*time.Minute)
+	assert.NoError(t, b.Apply(list))
+	assert.Equal(t, []time.Duration{0, 15 * time.Minute, 10 * time.Minute, 20 * time.Minute}, billed(list))
+}
+
+func TestApplyReportScope(t *testing.T) {
+	b, err := New(&config.Billing{
+		Rounding: &config.Rounding{Scope: "report", Unit: config.Duration(time.Hour)},
+		Rate:     95,
+		Currency: "EUR",
+	})
+	assert.NoError(t, err)
+
+	list := items(20*time.Minute, 20*time.Minute, 20*time.Minute)
+	assert.NoError(t, b.Apply(list))
+	assert.Equal(t, []time.Duration{20 * time.Minute, 20 * time.Minute, 20 * time.Minute}, billed(list))
+
+	list = items(20*time.Minute, 20*time.Minute, 50*time.Minute)
+	assert.NoError(t, b.Apply(list))
+	assert.Equal(t, []time.Duration{50 * time.Minute, 20 * time.Minute, 50 * time.Minute}, billed(list))
+	assert.Equal(t, "190.00 EUR", b.Money(2*time.Hour))
+	assert.Equal(t, 23.75, b.Amount(15*time.Minute))
+}
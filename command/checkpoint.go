@@ -0,0 +1,116 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+
+	"github.com/timeglass/glass/config"
+	daemon "github.com/timeglass/glass/glass-daemon"
+	"github.com/timeglass/glass/vcs"
+)
+
+type Checkpoint struct {
+	*command
+}
+
+func NewCheckpoint() *Checkpoint {
+	return &Checkpoint{newCommand()}
+}
+
+func (c *Checkpoint) Name() string {
+	return "checkpoint"
+}
+
+func (c *Checkpoint) Description() string {
+	return fmt.Sprintf("In directories without version control there are no commits to register time for, a checkpoint takes their place: it is recorded in the ledger of the directory (%s/%s) with the given message, the time of the timer is registered for it and the timer is reset. Checkpoints can be queried and exported just like commits", vcs.PlainDir, vcs.PlainLedger)
+}
+
+func (c *Checkpoint) Usage() string {
+	return "Register the measured time in a directory without version control"
+}
+
+func (c *Checkpoint) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{Name: "message,m", Value: "", Usage: "describes the work that was done since the last checkpoint"},
+	}
+}
+
+func (c *Checkpoint) Action() func(ctx *cli.Context) {
+	return c.command.Action(c.Run)
+}
+
+func (c *Checkpoint) Run(ctx *cli.Context) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
+	}
+
+	vc, err := getVCS(dir)
+	if err != nil {
+		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
+	}
+
+	plain, ok := vc.(*vcs.Plain)
+	if !ok {
+		return fmt.Errorf("Checkpoints are only used in directories without version control, '%s' uses %s: commit instead", vc.Root(), vc.Name())
+	}
+
+	sysdir, err := daemon.SystemTimeglassPath()
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to get system config path: {{err}}"), err)
+	}
+
+	project := getProject(vc, dir)
+	conf, err := config.ReadProjectConfig(vc.Root(), project, sysdir)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to read configuration: {{err}}"), err)
+	}
+
+	//a checkpoint without a running timer is still recorded
+	client := NewClient()
+	timer, err := client.ReadTimer(project)
+	if err != nil {
+		c.Printf("Failed to fetch timer, no time is registered: %s", err)
+	}
+
+	//like the commit hooks, held time isn't registered until it is confirmed
+	if timer != nil && timer.Held() != "" {
+		return fmt.Errorf("The timer of '%s' was held because it %s, run 'glass status' to keep or discard that time first", project, timer.Held())
+	}
+
+	if timer == nil {
+		id, err := plain.Checkpoint(ctx.String("message"), nil)
+		if err != nil {
+			return errwrap.Wrapf("Failed to record checkpoint: {{err}}", err)
+		}
+
+		c.Printf("Recorded checkpoint %s", id[:12])
+		return nil
+	}
+
+	entry := &vcs.TimeEntry{
+		Project: projectName(vc, project),
+		Time:    timer.Time(),
+		MBU:     time.Duration(conf.MBU),
+		Machine: vcs.MachineID(),
+		Start:   timer.SessionStart(),
+		End:     timer.SessionEnd(),
+	}
+
+	id, err := plain.Checkpoint(ctx.String("message"), entry)
+	if err != nil {
+		return errwrap.Wrapf("Failed to record checkpoint with its time: {{err}}", err)
+	}
+
+	err = client.ResetTimer(project, false)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to reset timer: {{err}}"), err)
+	}
+
+	c.Printf("Recorded checkpoint %s with %s", id[:12], entry.Time)
+	return nil
+}
@@ -8,10 +8,12 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
 
+	"github.com/timeglass/glass/config"
 	daemon "github.com/timeglass/glass/glass-daemon"
 )
 
@@ -77,9 +79,17 @@ func (c *Client) Info() (map[string]interface{}, error) {
 	return v, nil
 }
 
-func (c *Client) CreateTimer(dir string) error {
+func (c *Client) CreateTimer(dir, root string, excludes []string) error {
 	params := url.Values{}
 	params.Set("dir", dir)
+	params.Set("root", root)
+	if userdir, err := config.UserPath(); err == nil {
+		params.Set("user_dir", userdir)
+	}
+
+	for _, ex := range excludes {
+		params.Add("exclude", ex)
+	}
 
 	_, err := c.Call("timers.create", params)
 	if err != nil {
@@ -101,9 +111,12 @@ func (c *Client) DeleteTimer(dir string) error {
 	return nil
 }
 
-func (c *Client) ResetTimer(dir string) error {
+func (c *Client) ResetTimer(dir string, force bool) error {
 	params := url.Values{}
 	params.Set("dir", dir)
+	if force {
+		params.Set("force", "true")
+	}
 
 	_, err := c.Call("timers.reset", params)
 	if err != nil {
@@ -125,6 +138,62 @@ func (c *Client) PauseTimer(dir string) error {
 	return nil
 }
 
+// ConfirmTimer releases a timer that was held after reaching a limit,
+// the time of the session that reached it is kept or discarded
+func (c *Client) ConfirmTimer(dir string, keep bool) error {
+	params := url.Values{}
+	params.Set("dir", dir)
+	params.Set("keep", strconv.FormatBool(keep))
+
+	_, err := c.Call("timers.confirm", params)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Heartbeat tells the timer of the project in dir that a file is being
+// worked on, without a dir the timer of the innermost project that holds
+// the file is used. The timer is returned as it is after the heartbeat
+func (c *Client) Heartbeat(file, dir, source string) (*daemon.Timer, error) {
+	params := url.Values{}
+	params.Set("file", file)
+	params.Set("dir", dir)
+	params.Set("source", source)
+
+	data, err := c.Call("timers.heartbeat", params)
+	if err != nil {
+		return nil, err
+	}
+
+	timer := &daemon.Timer{}
+	err = json.Unmarshal(data, timer)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to deserialize '%s' into a timer: {{err}}", data), err)
+	}
+
+	return timer, nil
+}
+
+func (c *Client) SetBudgets(dir string, budgets []*daemon.Budget) error {
+	data, err := json.Marshal(budgets)
+	if err != nil {
+		return errwrap.Wrapf("Failed to serialize budgets: {{err}}", err)
+	}
+
+	params := url.Values{}
+	params.Set("dir", dir)
+	params.Set("budgets", string(data))
+
+	_, err = c.Call("timers.budget", params)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (c *Client) ReadTimer(dir string) (*daemon.Timer, error) {
 	timers := []*daemon.Timer{}
 	params := url.Values{}
@@ -0,0 +1,157 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+
+	"github.com/timeglass/glass/config"
+	daemon "github.com/timeglass/glass/glass-daemon"
+)
+
+type Config struct {
+	*command
+}
+
+func NewConfig() *Config {
+	return &Config{newCommand()}
+}
+
+func (c *Config) Name() string {
+	return "config"
+}
+
+func (c *Config) Description() string {
+	return fmt.Sprintf("Provide the action as the first argument: 'show' writes the effective value of every option to Stdout together with where it came from (the defaults, the system wide file, the file of the user, the file of the project, that of a sub-project or an environment variable), 'get KEY' writes the effective value of a single option and 'set KEY VALUE' changes an option in the file of the (sub-)project, in the system wide file with --system or in that of the current user with --user. Nested options are joined with a dot, e.g: 'issues.split'. Values that are valid JSON are used as such, e.g: false or [80,100], anything else is taken as a string. Files are validated strictly: unknown options and invalid values are rejected")
+}
+
+func (c *Config) Usage() string {
+	return "Show and change the configuration"
+}
+
+func (c *Config) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.BoolFlag{Name: "system", Usage: "change the system wide configuration instead of that of the project"},
+		cli.BoolFlag{Name: "user", Usage: "change the configuration of the current user instead of that of the project"},
+	}
+}
+
+func (c *Config) Action() func(ctx *cli.Context) {
+	return c.command.Action(c.Run)
+}
+
+// encodes a value as JSON without escaping characters that
+// are common in templates, such as '<' and '&'
+func encodeValue(v interface{}) (string, error) {
+	buff := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(buff)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(v)
+	return strings.TrimSpace(buff.String()), err
+}
+
+func (c *Config) Run(ctx *cli.Context) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
+	}
+
+	vc, err := getVCS(dir)
+	if err != nil {
+		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
+	}
+
+	sysdir, err := daemon.SystemTimeglassPath()
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to get system config path: {{err}}"), err)
+	}
+
+	project := getProject(vc, dir)
+	args := ctx.Args()
+	switch args.First() {
+	case "show":
+		_, settings, err := config.ReadSettings(vc.Root(), project, sysdir)
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("Failed to read configuration: {{err}}"), err)
+		}
+
+		for _, key := range settings.Keys() {
+			v, _ := settings.Get(key)
+			encoded, err := encodeValue(v)
+			if err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("Failed to serialize value of '%s': {{err}}", key), err)
+			}
+
+			fmt.Fprintf(os.Stdout, "%s = %s\t# %s\n", key, encoded, settings.Source(key))
+		}
+	case "get":
+		key := args.Get(1)
+		if key == "" {
+			return fmt.Errorf("Please provide the option to get as the second argument")
+		}
+
+		_, settings, err := config.ReadSettings(vc.Root(), project, sysdir)
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("Failed to read configuration: {{err}}"), err)
+		}
+
+		v, ok := settings.Get(key)
+		if !ok {
+			return fmt.Errorf("Option '%s' is not set", key)
+		}
+
+		//strings are written as is such that they can be used in scripts
+		if s, ok := v.(string); ok {
+			fmt.Fprintln(os.Stdout, s)
+			return nil
+		}
+
+		encoded, err := encodeValue(v)
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("Failed to serialize value of '%s': {{err}}", key), err)
+		}
+
+		fmt.Fprintln(os.Stdout, encoded)
+	case "set":
+		if len(args) != 3 {
+			return fmt.Errorf("Please provide the option and its value, e.g: glass config set auto_push false")
+		}
+
+		path := config.Path(project)
+		if ctx.Bool("system") && ctx.Bool("user") {
+			return fmt.Errorf("Please provide only one of --system or --user")
+		} else if config.Privileged(args.Get(1)) && !ctx.Bool("system") && !ctx.Bool("user") {
+			return fmt.Errorf("Option '%s' is only read from the system wide or user configuration, please provide --system or --user", args.Get(1))
+		} else if ctx.Bool("system") {
+			path = config.Path(sysdir)
+		} else if ctx.Bool("user") {
+			userdir, err := config.UserPath()
+			if err != nil {
+				return errwrap.Wrapf("Failed to get user config path: {{err}}", err)
+			}
+
+			err = os.MkdirAll(userdir, 0755)
+			if err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("Failed to create user config dir '%s': {{err}}", userdir), err)
+			}
+
+			path = config.Path(userdir)
+		}
+
+		err = config.SetValue(path, args.Get(1), config.ParseValue(args.Get(2)))
+		if err != nil {
+			return err
+		}
+
+		c.Printf("Set '%s' in '%s'", args.Get(1), path)
+	default:
+		return fmt.Errorf("Unknown action '%s', expected one of: show, get, set", args.First())
+	}
+
+	return nil
+}
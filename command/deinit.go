@@ -0,0 +1,130 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+	"github.com/timeglass/glass/_vendor/github.com/mattn/go-isatty"
+
+	"github.com/timeglass/glass/vcs"
+)
+
+type Deinit struct {
+	*command
+}
+
+func NewDeinit() *Deinit {
+	return &Deinit{newCommand()}
+}
+
+func (c *Deinit) Name() string {
+	return "deinit"
+}
+
+func (c *Deinit) Description() string {
+	return fmt.Sprintf("Removes Timeglass from the current repository: the timer is deleted, the parts of the hooks that were installed by 'glass init' are removed (hooks that were moved aside are put back) and configuration and references that Timeglass added are removed. Time data is kept unless --delete-data is given, use --push to push it to the default remote first; when neither is given you're asked whether to push. Use --dry-run to list what would change.")
+}
+
+func (c *Deinit) Usage() string {
+	return "Remove Timeglass from the current repository"
+}
+
+func (c *Deinit) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.BoolFlag{Name: "dry-run", Usage: "only list what would change"},
+		cli.BoolFlag{Name: "push", Usage: "push time data to the default remote before removing anything"},
+		cli.BoolFlag{Name: "delete-data", Usage: "also delete the local time data and estimates"},
+	}
+}
+
+func (c *Deinit) Action() func(ctx *cli.Context) {
+	return c.command.Action(c.Run)
+}
+
+// asks a yes/no question on the terminal, anything but yes is no
+func (c *Deinit) confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func (c *Deinit) Run(ctx *cli.Context) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
+	}
+
+	//a rolled up submodule only removes what it set up itself while it was
+	//independent, the superproject is left alone: run deinit in it instead
+	vc, err := getOwnVCS(dir)
+	if err != nil {
+		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
+	}
+
+	dry := ctx.Bool("dry-run")
+	push := ctx.Bool("push")
+	if remote, err := vc.DefaultRemote(); err == nil {
+		if !push && !ctx.Bool("delete-data") && !dry && isatty.IsTerminal(os.Stdin.Fd()) {
+			push = c.confirm(fmt.Sprintf("Push time data to '%s' before removing Timeglass?", remote))
+		}
+
+		if push && dry {
+			c.Printf("Would push time data to '%s'", remote)
+		} else if push {
+			c.Printf("Pushing time data to '%s'...", remote)
+			err = vc.Push(remote, "")
+			if err != nil && err != vcs.ErrNoLocalTimeData {
+				return errwrap.Wrapf("Failed to push time data, nothing was removed: {{err}}", err)
+			}
+		}
+	} else if push {
+		return fmt.Errorf("No remote to push time data to, nothing was removed")
+	}
+
+	//the timers of the root and of all sub-projects are deleted,
+	//the daemon might not be running, which is fine
+	projects, err := projectDirs(vc.Root())
+	if err != nil {
+		return err
+	}
+
+	for _, project := range projects {
+		if dry {
+			c.Printf("Would delete the timer of '%s'", project)
+			continue
+		}
+
+		err = NewClient().DeleteTimer(project)
+		if err != nil {
+			c.Printf("Failed to delete timer of '%s', continuing: %s", project, err)
+		} else {
+			c.Printf("Deleted the timer of '%s'", project)
+		}
+	}
+
+	changes, err := vc.Unhook(dry)
+	if err != nil {
+		return errwrap.Wrapf("Failed to remove hooks: {{err}}", err)
+	}
+
+	cleaned, err := vc.Cleanup(dry, ctx.Bool("delete-data"))
+	if err != nil {
+		return errwrap.Wrapf("Failed to remove configuration: {{err}}", err)
+	}
+
+	changes = append(changes, cleaned...)
+	for _, change := range changes {
+		c.Printf("%s", change)
+	}
+
+	if !ctx.Bool("delete-data") {
+		c.Printf("Time data was kept, use --delete-data to remove it as well")
+	}
+
+	return nil
+}
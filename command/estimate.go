@@ -0,0 +1,260 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+
+	"github.com/timeglass/glass/config"
+	daemon "github.com/timeglass/glass/glass-daemon"
+	"github.com/timeglass/glass/vcs"
+)
+
+type Estimate struct {
+	*command
+}
+
+func NewEstimate() *Estimate {
+	return &Estimate{newCommand()}
+}
+
+func (c *Estimate) Name() string {
+	return "estimate"
+}
+
+func (c *Estimate) Description() string {
+	return fmt.Sprintf("Records how much time is expected to be spent on the current branch, another branch (--branch) or an issue (--issue), e.g: 'glass estimate 6h'. Estimates are pushed and pulled together with the time data. Without a duration all estimates are listed, use --clear to remove an estimate. The status command shows the time spent on the estimates that apply to the current branch")
+}
+
+func (c *Estimate) Usage() string {
+	return "Estimate the time of a branch or issue"
+}
+
+func (c *Estimate) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{Name: "branch,b", Value: "", Usage: "estimate the given branch instead of the current one"},
+		cli.StringFlag{Name: "issue,i", Value: "", Usage: "estimate an issue key (e.g PROJ-12) instead of a branch"},
+		cli.BoolFlag{Name: "clear", Usage: "remove the estimate"},
+	}
+}
+
+func (c *Estimate) Action() func(ctx *cli.Context) {
+	return c.command.Action(c.Run)
+}
+
+func (c *Estimate) Run(ctx *cli.Context) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
+	}
+
+	vc, err := getVCS(dir)
+	if err != nil {
+		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
+	}
+
+	if ctx.Args().First() == "" && !ctx.Bool("clear") {
+		return c.list(vc)
+	}
+
+	key := vcs.IssueKey(ctx.String("issue"))
+	if ctx.String("issue") == "" {
+		branch := ctx.String("branch")
+		if branch == "" {
+			branch, err = vc.CurrentBranch()
+			if err != nil {
+				return err
+			}
+
+			if branch == "" {
+				return fmt.Errorf("Not on a branch, provide one with --branch or estimate an issue with --issue")
+			}
+		}
+
+		key = vcs.BranchKey(branch)
+	}
+
+	e := &vcs.Estimate{Key: key}
+	if !ctx.Bool("clear") {
+		e.Budget, err = time.ParseDuration(ctx.Args().First())
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("Failed to parse estimate '%s': {{err}}", ctx.Args().First()), err)
+		}
+
+		if e.Budget <= 0 {
+			return fmt.Errorf("Estimate must be positive, use --clear to remove it")
+		}
+	}
+
+	err = vc.SetEstimate(e)
+	if err != nil {
+		return errwrap.Wrapf("Failed to record estimate: {{err}}", err)
+	}
+
+	if e.Budget == 0 {
+		c.Printf("Removed estimate for %s", describeEstimate(e))
+	} else {
+		c.Printf("Estimated %s at %s", describeEstimate(e), e.Budget)
+	}
+
+	//let the timer know right away, it might not be running
+	err = syncBudgets(vc)
+	if err != nil {
+		c.Printf("Failed to update timer: %s", err)
+	}
+
+	return nil
+}
+
+func (c *Estimate) list(vc vcs.VCS) error {
+	estimates, err := vc.Estimates()
+	if err != nil {
+		return errwrap.Wrapf("Failed to read estimates: {{err}}", err)
+	}
+
+	keys := []string{}
+	for k, e := range estimates {
+		if e.Budget > 0 {
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) == 0 {
+		c.Printf("No estimates recorded (yet), estimate the current branch with e.g: 'glass estimate 6h'")
+		return nil
+	}
+
+	sort.Strings(keys)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "ESTIMATE\tBUDGET\tBY\tAT\n")
+	for _, k := range keys {
+		e := estimates[k]
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", describeEstimate(e), e.Budget, e.By, e.At.Local().Format("2006-01-02 15:04"))
+	}
+
+	return tw.Flush()
+}
+
+// e.g: "branch 'feature'"
+func describeEstimate(e *vcs.Estimate) string {
+	kind, name := e.Target()
+	return fmt.Sprintf("%s '%s'", kind, name)
+}
+
+// returns the budgets for the estimates that apply to the current
+// branch: the estimate of the branch itself, with the time of
+// commits that are unique to it, and estimates of issues that
+// are mentioned in the branch name, with the time of all
+// commits that mention the issue in their message
+func loadBudgets(vc vcs.VCS, conf *config.Config) ([]*daemon.Budget, error) {
+	budgets := []*daemon.Budget{}
+	estimates, err := vc.Estimates()
+	if err != nil {
+		return nil, errwrap.Wrapf("Failed to read estimates: {{err}}", err)
+	}
+
+	if len(estimates) == 0 {
+		return budgets, nil
+	}
+
+	branch, err := vc.CurrentBranch()
+	if err != nil || branch == "" {
+		return budgets, err
+	}
+
+	//issues are matched by key, e.g: PROJ-1 isn't the issue of a
+	//branch (or commit) that mentions PROJ-12
+	re, err := regexp.Compile(conf.Issues.Pattern)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to compile issue pattern '%s': {{err}}", conf.Issues.Pattern), err)
+	}
+
+	keys := []string{}
+	for k := range estimates {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		e := estimates[k]
+		kind, name := e.Target()
+		if e.Budget <= 0 {
+			continue
+		}
+
+		var hashes []string
+		switch {
+		case kind == "branch" && name == branch:
+			hashes, err = vc.BranchCommits(branch)
+			if err != nil {
+				return nil, err
+			}
+		case kind == "issue" && hasIssue(re, &vcs.Commit{Branch: branch}, name):
+			commits, err := vc.Log(&vcs.Query{All: true, Grep: name})
+			if err != nil {
+				return nil, errwrap.Wrapf(fmt.Sprintf("Failed to list commits of issue '%s': {{err}}", name), err)
+			}
+
+			//the text search also finds longer keys that start with it
+			for _, c := range commits {
+				if hasIssue(re, &vcs.Commit{Subject: c.Subject, Body: c.Body}, name) {
+					hashes = append(hashes, c.Hash)
+				}
+			}
+		default:
+			continue
+		}
+
+		notes, err := vc.ShowAll(hashes)
+		if err != nil {
+			return nil, errwrap.Wrapf("Failed to show time notes: {{err}}", err)
+		}
+
+		b := &daemon.Budget{Key: e.Key, Name: describeEstimate(e), Estimate: e.Budget, Thresholds: conf.EstimateWarnings}
+		for _, data := range notes {
+			b.Spent += data.Total()
+		}
+
+		budgets = append(budgets, b)
+	}
+
+	return budgets, nil
+}
+
+// sends the budgets of the current branch to the timer such
+// that it can warn when thresholds are crossed while working
+func syncBudgets(vc vcs.VCS) error {
+	sysdir, err := daemon.SystemTimeglassPath()
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to get system config path: {{err}}"), err)
+	}
+
+	conf, err := config.ReadConfig(vc.Root(), sysdir)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to read configuration: {{err}}"), err)
+	}
+
+	budgets, err := loadBudgets(vc, conf)
+	if err != nil {
+		return err
+	}
+
+	return NewClient().SetBudgets(vc.Root(), budgets)
+}
+
+// whether the key is one of the issue keys of the commit
+func hasIssue(re *regexp.Regexp, c *vcs.Commit, key string) bool {
+	for _, k := range issueKeys(re, c) {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
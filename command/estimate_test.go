@@ -0,0 +1,30 @@
+package command
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timeglass/glass/config"
+	"github.com/timeglass/glass/vcs"
+)
+
+func TestHasIssue(t *testing.T) {
+	re := regexp.MustCompile(config.DefaultConfig.Issues.Pattern)
+	for _, c := range []struct {
+		commit   *vcs.Commit
+		key      string
+		expected bool
+	}{
+		{&vcs.Commit{Branch: "feature/PROJ-1-login"}, "PROJ-1", true},
+		{&vcs.Commit{Branch: "feature/PROJ-12"}, "PROJ-1", false},
+		{&vcs.Commit{Branch: "feature/XPROJ-1"}, "PROJ-1", false},
+		{&vcs.Commit{Subject: "fix login", Body: "Fixes PROJ-1, PROJ-100"}, "PROJ-1", true},
+		{&vcs.Commit{Subject: "fix login for PROJ-12 and PROJ-100"}, "PROJ-1", false},
+		{&vcs.Commit{Subject: "closes #12"}, "#1", false},
+		{&vcs.Commit{Subject: "closes #12"}, "#12", true},
+	} {
+		assert.Equal(t, c.expected, hasIssue(re, c.commit, c.key), "%+v %s", c.commit, c.key)
+	}
+}
@@ -0,0 +1,483 @@
+package command
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+
+	"github.com/timeglass/glass/billing"
+	"github.com/timeglass/glass/config"
+	daemon "github.com/timeglass/glass/glass-daemon"
+	"github.com/timeglass/glass/vcs"
+)
+
+// a single contribution of an author to a commit
+type exportRow struct {
+	Commit  *vcs.Commit
+	Entry   *vcs.TimeEntry
+	Time    time.Duration
+	Project string
+	Client  string
+	Task    string
+
+	loc    *time.Location
+	biller *billing.Biller
+}
+
+// the moment the work started: the start of the measured
+// session if it is known, else the date of the commit
+func (r *exportRow) start() time.Time {
+	if !r.Entry.Start.IsZero() {
+		return r.Entry.Start.In(r.loc)
+	}
+
+	return r.Commit.Date.In(r.loc)
+}
+
+func (r *exportRow) names() (string, string) {
+	name := r.Commit.AuthorName
+	if r.Entry.Author != r.Commit.Author || name == "" {
+		name = strings.SplitN(r.Entry.Author, "@", 2)[0]
+	}
+
+	parts := strings.SplitN(name, " ", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+
+	return parts[0], parts[1]
+}
+
+var exportColumns = map[string]func(r *exportRow) string{
+	"date":   func(r *exportRow) string { return r.start().Format("2006-01-02") },
+	"time":   func(r *exportRow) string { return r.start().Format("15:04:05") },
+	"start":  func(r *exportRow) string { return r.start().Format(time.RFC3339) },
+	"end":    func(r *exportRow) string { return r.start().Add(r.Time).Format(time.RFC3339) },
+	"author": func(r *exportRow) string { return r.Entry.Author },
+	"first_name": func(r *exportRow) string {
+		first, _ := r.names()
+		return first
+	},
+	"last_name": func(r *exportRow) string {
+		_, last := r.names()
+		return last
+	},
+	"commit":   func(r *exportRow) string { return r.Commit.Hash },
+	"subject":  func(r *exportRow) string { return r.Commit.Subject },
+	"branch":   func(r *exportRow) string { return r.Commit.Branch },
+	"project":  func(r *exportRow) string { return r.Project },
+	"client":   func(r *exportRow) string { return r.Client },
+	"task":     func(r *exportRow) string { return r.Task },
+	"machine":  func(r *exportRow) string { return r.Entry.Machine },
+	"duration": func(r *exportRow) string { return r.Time.String() },
+	"clock": func(r *exportRow) string {
+		s := int64(r.Time.Seconds())
+		return fmt.Sprintf("%02d:%02d:%02d", s/3600, (s/60)%60, s%60)
+	},
+	"hours":     func(r *exportRow) string { return fmt.Sprintf("%.2f", r.Time.Hours()) },
+	"minutes":   func(r *exportRow) string { return fmt.Sprintf("%.0f", r.Time.Minutes()) },
+	"seconds":   func(r *exportRow) string { return fmt.Sprintf("%.0f", r.Time.Seconds()) },
+	"measured":  func(r *exportRow) string { return r.Entry.Time.String() },
+	"off_hours": func(r *exportRow) string { return r.Entry.OffHours.String() },
+	"amount":    func(r *exportRow) string { return fmt.Sprintf("%.2f", r.biller.Amount(r.Time)) },
+	"currency":  func(r *exportRow) string { return r.biller.Currency() },
+}
+
+type exportFormat struct {
+	Columns []string
+	Headers map[string]string
+	Write   func(w io.Writer, f *exportFormat, columns []string, rows []*exportRow) error
+}
+
+func (f *exportFormat) header(col string) string {
+	if h, ok := f.Headers[col]; ok {
+		return h
+	}
+
+	return col
+}
+
+var exportFormats = map[string]*exportFormat{
+	"csv": {
+		Columns: []string{"date", "author", "commit", "subject", "branch", "duration", "hours"},
+		Write:   writeExportCSV,
+	},
+	"toggl": {
+		Columns: []string{"author", "date", "time", "clock", "project", "subject"},
+		Headers: map[string]string{
+			"author":  "Email",
+			"date":    "Start date",
+			"time":    "Start time",
+			"clock":   "Duration",
+			"project": "Project",
+			"client":  "Client",
+			"task":    "Task",
+			"subject": "Description",
+		},
+		Write: writeExportCSV,
+	},
+	"harvest": {
+		Columns: []string{"date", "client", "project", "task", "subject", "hours", "first_name", "last_name"},
+		Headers: map[string]string{
+			"date":       "Date",
+			"client":     "Client",
+			"project":    "Project",
+			"task":       "Task",
+			"subject":    "Notes",
+			"hours":      "Hours",
+			"first_name": "First name",
+			"last_name":  "Last name",
+		},
+		Write: writeExportCSV,
+	},
+	"ics": {
+		Columns: []string{"commit", "author", "duration"},
+		Write:   writeExportICS,
+	},
+	"json": {
+		Columns: []string{"date", "start", "end", "author", "commit", "subject", "branch", "project", "duration", "seconds"},
+		Write:   writeExportJSON,
+	},
+}
+
+type Export struct {
+	*command
+}
+
+func NewExport() *Export {
+	return &Export{newCommand()}
+}
+
+func (c *Export) Name() string {
+	return "export"
+}
+
+func (c *Export) Description() string {
+	return fmt.Sprintf("Writes the time recorded for the commits in the given revision range (e.g v0.5.0..HEAD, defaults to HEAD) as a timesheet, one row per author per commit. Supported formats are: a generic 'csv', 'toggl' and 'harvest' compatible csv, an iCalendar 'ics' file of measured sessions and 'json'. Columns, timezone and rounding can be configured per format in the 'export' section of the configuration or with flags, without rounding options the billing rules of the configuration are applied")
+}
+
+func (c *Export) Usage() string {
+	return "Export time measurements as a timesheet"
+}
+
+func (c *Export) Flags() []cli.Flag {
+	return append(queryFlags(),
+		cli.StringFlag{Name: "format,f", Value: "csv", Usage: "output format: 'csv', 'toggl', 'harvest', 'ics' or 'json'"},
+		cli.StringFlag{Name: "columns", Value: "", Usage: "comma separated list of columns, overwrites the format's default"},
+		cli.StringFlag{Name: "timezone", Value: "", Usage: "timezone for dates and times (e.g Europe/Amsterdam), defaults to the local timezone"},
+		cli.StringFlag{Name: "round", Value: "", Usage: "round the time of each row to a multiple of this duration (e.g 15m)"},
+		cli.StringFlag{Name: "round-mode", Value: "", Usage: "round 'up', 'down' or to the 'nearest' multiple (default)"},
+		cli.StringFlag{Name: "output,o", Value: "", Usage: "write to the given file instead of Stdout"},
+	)
+}
+
+func (c *Export) Action() func(ctx *cli.Context) {
+	return c.command.Action(c.Run)
+}
+
+func (c *Export) Run(ctx *cli.Context) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
+	}
+
+	vc, err := getVCS(dir)
+	if err != nil {
+		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
+	}
+
+	sysdir, err := daemon.SystemTimeglassPath()
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to get system config path: {{err}}"), err)
+	}
+
+	conf, err := config.ReadConfig(vc.Root(), sysdir)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to read configuration: {{err}}"), err)
+	}
+
+	name := ctx.String("format")
+	format, ok := exportFormats[name]
+	if !ok {
+		return fmt.Errorf("Unknown format '%s', expected one of: csv, toggl, harvest, ics or json", name)
+	}
+
+	//flags take precedence over the configuration of the format
+	econf := &config.ExportConfig{}
+	if conf.Export != nil && conf.Export[name] != nil {
+		econf = conf.Export[name]
+	}
+
+	columns := format.Columns
+	if len(econf.Columns) > 0 {
+		columns = econf.Columns
+	}
+
+	if ctx.String("columns") != "" {
+		columns = strings.Split(ctx.String("columns"), ",")
+	}
+
+	for _, col := range columns {
+		if _, ok := exportColumns[col]; !ok {
+			return fmt.Errorf("Unknown column '%s'", col)
+		}
+	}
+
+	tz := econf.Timezone
+	if ctx.String("timezone") != "" {
+		tz = ctx.String("timezone")
+	}
+
+	loc, err := loadLocation(tz)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to load timezone '%s': {{err}}", tz), err)
+	}
+
+	unit := time.Duration(econf.Round)
+	if ctx.String("round") != "" {
+		unit, err = time.ParseDuration(ctx.String("round"))
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("Failed to parse rounding unit '%s': {{err}}", ctx.String("round")), err)
+		}
+	}
+
+	mode := econf.RoundMode
+	if ctx.String("round-mode") != "" {
+		mode = ctx.String("round-mode")
+	}
+
+	biller, err := billing.New(conf.Billing)
+	if err != nil {
+		return errwrap.Wrapf("Invalid billing configuration: {{err}}", err)
+	}
+
+	project := econf.Project
+	if project == "" {
+		project = filepath.Base(vc.Root())
+	}
+
+	//select commits and their time data
+	q := buildQuery(ctx)
+	q.Branches = true
+	commits, err := vc.Log(q)
+	if err != nil {
+		return errwrap.Wrapf("Failed to list commits: {{err}}", err)
+	}
+
+	hashes := []string{}
+	for _, commit := range commits {
+		hashes = append(hashes, commit.Hash)
+	}
+
+	notes, err := vc.ShowAll(hashes)
+	if err != nil {
+		return errwrap.Wrapf("Failed to show time notes: {{err}}", err)
+	}
+
+	rows := []*exportRow{}
+	for _, commit := range commits {
+		data, ok := notes[commit.Hash]
+		if !ok {
+			continue
+		}
+
+		//legacy notes are attributed to the commit author
+		entries := data.Entries()
+		if len(entries) == 0 {
+			entries = []*vcs.TimeEntry{{Author: commit.Author, Time: data.Total()}}
+		}
+
+		//time of sub-projects is exported under their own name
+		for _, e := range entries {
+			p := project
+			if e.Project != "" {
+				p = e.Project
+			}
+
+			rows = append(rows, &exportRow{
+				Commit:  commit,
+				Entry:   e,
+				Time:    e.Time,
+				Project: p,
+				Client:  econf.Client,
+				Task:    econf.Task,
+				loc:     loc,
+				biller:  biller,
+			})
+		}
+	}
+
+	err = roundExport(rows, unit, mode, biller)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if path := ctx.String("output"); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("Failed to create output file '%s': {{err}}", path), err)
+		}
+
+		defer f.Close()
+		w = f
+	}
+
+	c.Printf("Exporting %d row(s) as '%s'...", len(rows), name)
+	return format.Write(w, format, columns, rows)
+}
+
+// rounding options of the format replace the rounding of the
+// billing rules, each row is then rounded on its own
+func roundExport(rows []*exportRow, unit time.Duration, mode string, biller *billing.Biller) error {
+	if unit > 0 {
+		for _, r := range rows {
+			t, err := billing.Round(r.Time, unit, mode)
+			if err != nil {
+				return err
+			}
+
+			r.Time = t
+		}
+
+		return nil
+	}
+
+	items := []*billing.Item{}
+	for _, r := range rows {
+		items = append(items, &billing.Item{Date: r.start(), Time: r.Time})
+	}
+
+	err := biller.Apply(items)
+	if err != nil {
+		return err
+	}
+
+	for i, r := range rows {
+		r.Time = items[i].Billed
+	}
+
+	return nil
+}
+
+func writeExportCSV(w io.Writer, f *exportFormat, columns []string, rows []*exportRow) error {
+	cw := csv.NewWriter(w)
+	header := []string{}
+	for _, col := range columns {
+		header = append(header, f.header(col))
+	}
+
+	cw.Write(header)
+	for _, r := range rows {
+		record := []string{}
+		for _, col := range columns {
+			record = append(record, exportColumns[col](r))
+		}
+
+		cw.Write(record)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeExportJSON(w io.Writer, f *exportFormat, columns []string, rows []*exportRow) error {
+	data := []map[string]string{}
+	for _, r := range rows {
+		obj := map[string]string{}
+		for _, col := range columns {
+			obj[f.header(col)] = exportColumns[col](r)
+		}
+
+		data = append(data, obj)
+	}
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(data)
+}
+
+// escapes text values according to RFC 5545
+var icsEscaper = strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\n", "\\n")
+
+// writes content lines, the first error is kept
+// and writing stops after it
+type icsWriter struct {
+	w   io.Writer
+	err error
+}
+
+// writes a content line, folding it after (at most) 75
+// octets without splitting multi-octet characters
+func (w *icsWriter) line(line string) {
+	for len(line) > 75 && w.err == nil {
+		cut := 75
+		for !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		_, w.err = fmt.Fprintf(w.w, "%s\r\n", line[:cut])
+		line = " " + line[cut:]
+	}
+
+	if w.err == nil {
+		_, w.err = fmt.Fprintf(w.w, "%s\r\n", line)
+	}
+}
+
+// writes an event for each row that has session timestamps, times are
+// always written in UTC such that no timezone definitions are required
+func writeExportICS(w io.Writer, f *exportFormat, columns []string, rows []*exportRow) error {
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	iw := &icsWriter{w: w}
+	iw.line("BEGIN:VCALENDAR")
+	iw.line("VERSION:2.0")
+	iw.line("PRODID:-//Timeglass//glass//EN")
+	for _, r := range rows {
+		if r.Entry.Start.IsZero() {
+			continue
+		}
+
+		desc := []string{}
+		for _, col := range columns {
+			desc = append(desc, fmt.Sprintf("%s: %s", f.header(col), exportColumns[col](r)))
+		}
+
+		//entries of sub-projects share the commit and author
+		uid := r.Commit.Hash + "-" + r.Entry.Author
+		if r.Entry.Project != "" {
+			uid += "-" + r.Entry.Project
+		}
+
+		start := r.Entry.Start.UTC()
+		iw.line("BEGIN:VEVENT")
+		iw.line("UID:" + icsEscaper.Replace(uid) + "@timeglass")
+		iw.line("DTSTAMP:" + stamp)
+		iw.line("DTSTART:" + start.Format("20060102T150405Z"))
+		iw.line("DTEND:" + start.Add(r.Time).Format("20060102T150405Z"))
+		iw.line("SUMMARY:" + icsEscaper.Replace(r.Commit.Subject))
+		iw.line("DESCRIPTION:" + icsEscaper.Replace(strings.Join(desc, "\n")))
+		iw.line("END:VEVENT")
+	}
+
+	iw.line("END:VCALENDAR")
+	return iw.err
+}
+
+// the time zone of the export, the local time zone
+// is used when none is given (unlike time.LoadLocation)
+func loadLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.Local, nil
+	}
+
+	return time.LoadLocation(tz)
+}
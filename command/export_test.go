@@ -0,0 +1,149 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timeglass/glass/billing"
+	"github.com/timeglass/glass/config"
+	"github.com/timeglass/glass/vcs"
+)
+
+// rows as they are selected by the export command: two authors on
+// the first commit, a sub-project and a legacy note without a session
+func exportRows(b *billing.Biller) []*exportRow {
+	start := time.Date(2015, 6, 1, 9, 30, 0, 0, time.UTC)
+	first := &vcs.Commit{Hash: "3f2a9c0b1d4e5f60718293a4b5c6d7e8f9a0b1c2", Date: start.Add(time.Hour), Author: "alice@example.com", AuthorName: "Alice van Dijk", Branch: "master", Subject: "Fix login, logout; and \"remember me\""}
+	second := &vcs.Commit{Hash: "1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d", Date: start.Add(26 * time.Hour), Author: "bob@example.com", Subject: "Add export"}
+	rows := []*exportRow{
+		{Commit: first, Entry: &vcs.TimeEntry{Author: "alice@example.com", Time: 52 * time.Minute, Start: start, Machine: "3f2a9c0b1d4e"}, Project: "glass"},
+		{Commit: first, Entry: &vcs.TimeEntry{Author: "bob@example.com", Time: 7 * time.Minute, OffHours: 2 * time.Minute}, Project: "glass"},
+		{Commit: first, Entry: &vcs.TimeEntry{Author: "alice@example.com", Project: "services/api", Time: 20 * time.Minute, Start: start.Add(time.Hour)}, Project: "services/api"},
+		{Commit: second, Entry: &vcs.TimeEntry{Author: "bob@example.com", Time: 95*time.Minute + 30*time.Second}, Project: "glass"},
+	}
+
+	for _, r := range rows {
+		r.Time = r.Entry.Time
+		r.Client = "Acme"
+		r.Task = "Development"
+		r.loc = time.UTC
+		r.biller = b
+	}
+
+	return rows
+}
+
+// the time the calendar was written differs for every run
+var icsStamp = regexp.MustCompile(`DTSTAMP:\d{8}T\d{6}Z`)
+
+func TestExportFormats(t *testing.T) {
+	for name, format := range exportFormats {
+		b, err := billing.New(nil)
+		assert.NoError(t, err)
+
+		buf := bytes.NewBuffer(nil)
+		assert.NoError(t, format.Write(buf, format, format.Columns, exportRows(b)), name)
+
+		out := icsStamp.ReplaceAll(buf.Bytes(), []byte("DTSTAMP:20150601T000000Z"))
+		assertGolden(t, "export_"+name, out)
+	}
+}
+
+func TestExportICSUniqueEvents(t *testing.T) {
+	b, err := billing.New(nil)
+	assert.NoError(t, err)
+
+	rows := exportRows(b)
+	rows[2].Entry.Author = rows[0].Entry.Author
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, writeExportICS(buf, exportFormats["ics"], exportFormats["ics"].Columns, rows))
+
+	uids := regexp.MustCompile(`UID:(.*)\r\n`).FindAllStringSubmatch(buf.String(), -1)
+	if assert.Len(t, uids, 2) {
+		assert.NotEqual(t, uids[0][1], uids[1][1])
+	}
+}
+
+// fails after the given number of bytes
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return 0, errors.New("disk full")
+	}
+
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestExportWriteErrors(t *testing.T) {
+	b, err := billing.New(nil)
+	assert.NoError(t, err)
+
+	for name, format := range exportFormats {
+		err := format.Write(&failingWriter{n: 100}, format, format.Columns, exportRows(b))
+		if assert.Error(t, err, name) {
+			assert.Contains(t, err.Error(), "disk full", name)
+		}
+	}
+}
+
+func TestRoundExport(t *testing.T) {
+	billed, err := billing.New(&config.Billing{Rounding: &config.Rounding{Scope: "commit", Mode: "up", Unit: config.Duration(15 * time.Minute)}})
+	assert.NoError(t, err)
+	unbilled, err := billing.New(nil)
+	assert.NoError(t, err)
+
+	for _, c := range []struct {
+		unit     time.Duration
+		mode     string
+		biller   *billing.Biller
+		expected []time.Duration
+		err      string
+	}{
+		{0, "", unbilled, []time.Duration{52 * time.Minute, 7 * time.Minute, 20 * time.Minute, 95*time.Minute + 30*time.Second}, ""},
+		{15 * time.Minute, "", unbilled, []time.Duration{45 * time.Minute, 0, 15 * time.Minute, 90 * time.Minute}, ""},
+		{15 * time.Minute, "up", unbilled, []time.Duration{time.Hour, 15 * time.Minute, 30 * time.Minute, 105 * time.Minute}, ""},
+		{15 * time.Minute, "down", unbilled, []time.Duration{45 * time.Minute, 0, 15 * time.Minute, 90 * time.Minute}, ""},
+		{15 * time.Minute, "sideways", unbilled, nil, "sideways"},
+
+		//the options of the format replace the billing rules
+		{0, "", billed, []time.Duration{time.Hour, 15 * time.Minute, 30 * time.Minute, 105 * time.Minute}, ""},
+		{time.Minute, "down", billed, []time.Duration{52 * time.Minute, 7 * time.Minute, 20 * time.Minute, 95 * time.Minute}, ""},
+	} {
+		rows := exportRows(c.biller)
+		err := roundExport(rows, c.unit, c.mode, c.biller)
+		if c.err != "" {
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), c.err)
+			}
+
+			continue
+		}
+
+		assert.NoError(t, err)
+		for i, r := range rows {
+			assert.Equal(t, c.expected[i], r.Time, "%s %s row %d", c.unit, c.mode, i)
+		}
+	}
+}
+
+func TestLoadLocation(t *testing.T) {
+	loc, err := loadLocation("")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Local, loc)
+
+	loc, err = loadLocation("America/New_York")
+	assert.NoError(t, err)
+	assert.Equal(t, "America/New_York", loc.String())
+
+	_, err = loadLocation("Mars/Olympus_Mons")
+	assert.Error(t, err)
+}
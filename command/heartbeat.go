@@ -0,0 +1,77 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+)
+
+type Heartbeat struct {
+	*command
+}
+
+func NewHeartbeat() *Heartbeat {
+	return &Heartbeat{newCommand()}
+}
+
+func (c *Heartbeat) Name() string {
+	return "heartbeat"
+}
+
+func (c *Heartbeat) Description() string {
+	return fmt.Sprintf("Tells the timer that a file (or else the current directory) is being worked on without writing to it, e.g: while reading or debugging code. A heartbeat counts as activity exactly like a written file: it resumes a paused timer and postpones the timeout. Editors can call it with the file that is being viewed, the source (e.g: 'vim') is recorded with the timer. Editors can also call the '/api/timers.heartbeat' endpoint of the background service directly")
+}
+
+func (c *Heartbeat) Usage() string {
+	return "Keep the timer running while working without writing files"
+}
+
+func (c *Heartbeat) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{Name: "source", Value: "cli", Usage: "what sends the heartbeat, e.g: the name of the editor"},
+	}
+}
+
+func (c *Heartbeat) Action() func(ctx *cli.Context) {
+	return c.command.Action(c.Run)
+}
+
+func (c *Heartbeat) Run(ctx *cli.Context) error {
+	file := ctx.Args().First()
+	if file == "" {
+		file = "."
+	}
+
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to get absolute path of '%s': {{err}}", ctx.Args().First()), err)
+	}
+
+	//the project is that of the directory that holds the file
+	dir := file
+	if fi, err := os.Stat(file); err != nil || !fi.IsDir() {
+		dir = filepath.Dir(file)
+	}
+
+	vc, err := getVCS(dir)
+	if err != nil {
+		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
+	}
+
+	project := getProject(vc, dir)
+	timer, err := NewClient().Heartbeat(file, project, ctx.String("source"))
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to send heartbeat: {{err}}"), err)
+	}
+
+	if timer.IsPaused() {
+		c.Printf("Timer of '%s' received the heartbeat but stays paused", project)
+	} else {
+		c.Printf("Timer of '%s' received the heartbeat and is running", project)
+	}
+
+	return nil
+}
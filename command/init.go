@@ -23,7 +23,7 @@ func (c *Init) Name() string {
 }
 
 func (c *Init) Description() string {
-	return fmt.Sprintf("Install hooks, start timer and pull measuremnets for the current repository, if hooks already exists they are truncated and rewritten.")
+	return fmt.Sprintf("Install hooks, start timer and pull measuremnets for the current repository. Hooks that already exist keep working: Timeglass adds a marked block to them or moves them aside and runs them before its own commands, 'glass deinit' removes only the Timeglass parts again.")
 }
 
 func (c *Init) Usage() string {
@@ -45,16 +45,30 @@ func (c *Init) Run(ctx *cli.Context) error {
 		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
 	}
 
-	vc, err := vcs.GetVCS(dir)
+	vc, err := getVCS(dir)
 	if err != nil {
 		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
 	}
 
-	err = vc.Hook()
+	//a rolled up submodule has no timer or hooks of its own
+	own, err := getOwnVCS(dir)
+	if err != nil {
+		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
+	}
+
+	if own.Root() != vc.Root() {
+		return fmt.Errorf("The submodule '%s' is rolled up into its superproject '%s', its time is already registered with the commits of the superproject. Run 'glass init' in the superproject instead or set 'submodules' to 'independent' in its configuration", own.Root(), vc.Root())
+	}
+
+	changes, err := vc.Hook()
 	if err != nil {
 		return errwrap.Wrapf("Failed to write hooks: {{err}}", err)
 	}
 
+	for _, change := range changes {
+		c.Printf("%s", change)
+	}
+
 	c.Println("Hooks written!")
 	err = NewStart().Run(ctx)
 	if err != nil {
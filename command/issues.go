@@ -0,0 +1,298 @@
+package command
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+
+	"github.com/timeglass/glass/config"
+	daemon "github.com/timeglass/glass/glass-daemon"
+	"github.com/timeglass/glass/vcs"
+)
+
+// the time that was attributed to a single issue
+type issueRow struct {
+	Key     string
+	Commits int
+	Time    time.Duration
+}
+
+// divides the time of a commit over its issue keys
+var issueSplits = map[string]func(keys []string, t time.Duration) []time.Duration{
+	"even": func(keys []string, t time.Duration) []time.Duration {
+		res := []time.Duration{}
+		part := t / time.Duration(len(keys))
+		for i := range keys {
+			res = append(res, part)
+			if i == 0 {
+				//the remainder goes to the first key such that nothing is lost
+				res[0] += t - part*time.Duration(len(keys))
+			}
+		}
+
+		return res
+	},
+	"first": func(keys []string, t time.Duration) []time.Duration {
+		res := make([]time.Duration, len(keys))
+		res[0] = t
+		return res
+	},
+	"full": func(keys []string, t time.Duration) []time.Duration {
+		res := []time.Duration{}
+		for range keys {
+			res = append(res, t)
+		}
+
+		return res
+	},
+}
+
+type issueRows []*issueRow
+
+func (r issueRows) Len() int           { return len(r) }
+func (r issueRows) Less(i, j int) bool { return issueLess(r[i].Key, r[j].Key) }
+func (r issueRows) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+var issueFormats = map[string]func(w io.Writer, rows []*issueRow, total time.Duration) error{
+	"table": writeIssuesTable,
+	"csv":   writeIssuesCSV,
+	"json":  writeIssuesJSON,
+}
+
+// finds the unique issue keys in the message and branch name of a
+// commit, in that order. If the pattern has a capturing group its
+// first group is used as the key instead of the whole match
+func issueKeys(re *regexp.Regexp, c *vcs.Commit) []string {
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, text := range []string{c.Subject, c.Body, c.Branch} {
+		for _, m := range re.FindAllStringSubmatch(text, -1) {
+			key := m[0]
+			if len(m) > 1 && m[1] != "" {
+				key = m[1]
+			}
+
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	return keys
+}
+
+// orders keys of the same project by their number, e.g:
+// PROJ-9 before PROJ-10, commits without issue go last
+func issueLess(a, b string) bool {
+	if a == "" || b == "" {
+		return a != "" && b == ""
+	}
+
+	split := func(s string) (string, int) {
+		i := len(s)
+		for i > 0 && s[i-1] >= '0' && s[i-1] <= '9' {
+			i--
+		}
+
+		n, _ := strconv.Atoi(s[i:])
+		return s[:i], n
+	}
+
+	pa, na := split(a)
+	pb, nb := split(b)
+	if pa != pb {
+		return pa < pb
+	}
+
+	return na < nb
+}
+
+func issueName(key string) string {
+	if key == "" {
+		return "(none)"
+	}
+
+	return key
+}
+
+type Issues struct {
+	*command
+}
+
+func NewIssues() *Issues {
+	return &Issues{newCommand()}
+}
+
+func (c *Issues) Name() string {
+	return "issues"
+}
+
+func (c *Issues) Description() string {
+	return fmt.Sprintf("Attributes the time of each commit in the given revision range (e.g v0.5.0..HEAD, defaults to HEAD) to the issue keys (e.g PROJ-123 or #42) that are found in its message or branch name and lists the total time per issue. The pattern for issue keys can be configured, time of commits with several keys is split 'even'-ly, goes to the 'first' key or counts in 'full' for every key. Time of commits without any key is listed as '(none)'")
+}
+
+func (c *Issues) Usage() string {
+	return "Report time measurements per issue"
+}
+
+func (c *Issues) Flags() []cli.Flag {
+	return append(queryFlags(),
+		cli.StringFlag{Name: "split", Value: "", Usage: "how time is divided over several keys: 'even', 'first' or 'full', overwrites the configuration"},
+		cli.StringFlag{Name: "format,f", Value: "table", Usage: "output format: 'table', 'csv' or 'json'"},
+	)
+}
+
+func (c *Issues) Action() func(ctx *cli.Context) {
+	return c.command.Action(c.Run)
+}
+
+func (c *Issues) Run(ctx *cli.Context) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
+	}
+
+	vc, err := getVCS(dir)
+	if err != nil {
+		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
+	}
+
+	sysdir, err := daemon.SystemTimeglassPath()
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to get system config path: {{err}}"), err)
+	}
+
+	conf, err := config.ReadConfig(vc.Root(), sysdir)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to read configuration: {{err}}"), err)
+	}
+
+	re, err := regexp.Compile(conf.Issues.Pattern)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to compile issue pattern '%s': {{err}}", conf.Issues.Pattern), err)
+	}
+
+	name := conf.Issues.Split
+	if ctx.String("split") != "" {
+		name = ctx.String("split")
+	}
+
+	split, ok := issueSplits[name]
+	if !ok {
+		return fmt.Errorf("Unknown split strategy '%s', expected one of: even, first or full", name)
+	}
+
+	write, ok := issueFormats[ctx.String("format")]
+	if !ok {
+		return fmt.Errorf("Unknown format '%s', expected one of: table, csv or json", ctx.String("format"))
+	}
+
+	q := buildQuery(ctx)
+	q.Branches = true
+	commits, err := vc.Log(q)
+	if err != nil {
+		return errwrap.Wrapf("Failed to list commits: {{err}}", err)
+	}
+
+	hashes := []string{}
+	for _, commit := range commits {
+		hashes = append(hashes, commit.Hash)
+	}
+
+	notes, err := vc.ShowAll(hashes)
+	if err != nil {
+		return errwrap.Wrapf("Failed to show time notes: {{err}}", err)
+	}
+
+	var total time.Duration
+	idx := map[string]*issueRow{}
+	for _, commit := range commits {
+		data, ok := notes[commit.Hash]
+		if !ok {
+			continue
+		}
+
+		keys := issueKeys(re, commit)
+		times := []time.Duration{data.Total()}
+		if len(keys) == 0 {
+			keys = []string{""}
+		} else {
+			times = split(keys, data.Total())
+		}
+
+		total += data.Total()
+		for i, key := range keys {
+			row, ok := idx[key]
+			if !ok {
+				row = &issueRow{Key: key}
+				idx[key] = row
+			}
+
+			row.Commits++
+			row.Time += times[i]
+		}
+	}
+
+	rows := []*issueRow{}
+	for _, row := range idx {
+		rows = append(rows, row)
+	}
+
+	sort.Sort(issueRows(rows))
+	return write(os.Stdout, rows, total)
+}
+
+func writeIssuesTable(w io.Writer, rows []*issueRow, total time.Duration) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "ISSUE\tCOMMITS\tTIME\n")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", issueName(r.Key), r.Commits, r.Time)
+	}
+
+	fmt.Fprintf(tw, "\t\t\ntotal\t\t%s\n", total)
+	return tw.Flush()
+}
+
+func writeIssuesCSV(w io.Writer, rows []*issueRow, total time.Duration) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"type", "issue", "commits", "time", "seconds"})
+	for _, r := range rows {
+		cw.Write([]string{"issue", r.Key, strconv.Itoa(r.Commits), r.Time.String(), fmt.Sprintf("%.0f", r.Time.Seconds())})
+	}
+
+	cw.Write([]string{"total", "", "", total.String(), fmt.Sprintf("%.0f", total.Seconds())})
+	cw.Flush()
+	return cw.Error()
+}
+
+type jsonIssue struct {
+	Key     string  `json:"key"`
+	Commits int     `json:"commits"`
+	Time    string  `json:"time"`
+	Seconds float64 `json:"seconds"`
+}
+
+func writeIssuesJSON(w io.Writer, rows []*issueRow, total time.Duration) error {
+	data := struct {
+		Issues  []*jsonIssue `json:"issues"`
+		Total   string       `json:"total"`
+		Seconds float64      `json:"seconds"`
+	}{[]*jsonIssue{}, total.String(), total.Seconds()}
+
+	for _, r := range rows {
+		data.Issues = append(data.Issues, &jsonIssue{r.Key, r.Commits, r.Time.String(), r.Time.Seconds()})
+	}
+
+	return json.NewEncoder(w).Encode(data)
+}
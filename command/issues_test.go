@@ -0,0 +1,78 @@
+package command
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timeglass/glass/config"
+	"github.com/timeglass/glass/vcs"
+)
+
+func TestIssueKeys(t *testing.T) {
+	re := regexp.MustCompile(config.DefaultConfig.Issues.Pattern)
+	for _, c := range []struct {
+		commit   *vcs.Commit
+		expected []string
+	}{
+		{&vcs.Commit{Subject: "fix typo"}, []string{}},
+		{&vcs.Commit{Subject: "PROJ-12: fix login", Body: "see #42"}, []string{"PROJ-12", "#42"}},
+
+		//the message goes before the branch, keys are only listed once
+		{&vcs.Commit{Subject: "PROJ-9 and PROJ-12", Body: "also PROJ-9", Branch: "feature/PROJ-12"}, []string{"PROJ-9", "PROJ-12"}},
+		{&vcs.Commit{Subject: "fix login", Branch: "feature/PROJ-12-login"}, []string{"PROJ-12"}},
+
+		//keys are whole words
+		{&vcs.Commit{Subject: "XPROJ12 proj-12 PROJ-12a PROJ- #x"}, []string{}},
+	} {
+		assert.Equal(t, c.expected, issueKeys(re, c.commit), c.commit.Subject)
+	}
+
+	//the first group of a pattern is the key
+	re = regexp.MustCompile(`\[([a-z]+-[0-9]+)\]`)
+	assert.Equal(t, []string{"api-3", "ui-1"}, issueKeys(re, &vcs.Commit{Subject: "[api-3] [ui-1] [api-3] api-4"}))
+}
+
+func TestIssueLess(t *testing.T) {
+	keys := []string{"", "PROJ-10", "#42", "API-2", "PROJ-9", "#7", "PROJ-100"}
+	sort.Slice(keys, func(i, j int) bool { return issueLess(keys[i], keys[j]) })
+	assert.Equal(t, []string{"#7", "#42", "API-2", "PROJ-9", "PROJ-10", "PROJ-100", ""}, keys)
+
+	assert.False(t, issueLess("", "PROJ-1"))
+	assert.True(t, issueLess("PROJ-1", ""))
+	assert.False(t, issueLess("", ""))
+}
+
+func TestIssueSplits(t *testing.T) {
+	keys := []string{"PROJ-1", "PROJ-2", "PROJ-3"}
+	for _, c := range []struct {
+		split    string
+		keys     []string
+		t        time.Duration
+		expected []time.Duration
+	}{
+		{"even", keys, 30 * time.Minute, []time.Duration{10 * time.Minute, 10 * time.Minute, 10 * time.Minute}},
+
+		//the remainder goes to the first key such that nothing is lost
+		{"even", keys, 10 * time.Nanosecond, []time.Duration{4, 3, 3}},
+		{"even", keys[:2], time.Minute + time.Nanosecond, []time.Duration{30*time.Second + 1, 30 * time.Second}},
+		{"even", keys[:1], time.Hour, []time.Duration{time.Hour}},
+		{"first", keys, time.Hour, []time.Duration{time.Hour, 0, 0}},
+		{"full", keys, time.Hour, []time.Duration{time.Hour, time.Hour, time.Hour}},
+	} {
+		res := issueSplits[c.split](c.keys, c.t)
+		assert.Equal(t, c.expected, res, "%s %s", c.split, c.t)
+
+		var sum time.Duration
+		for _, d := range res {
+			sum += d
+		}
+
+		if c.split != "full" {
+			assert.Equal(t, c.t, sum, "%s %s", c.split, c.t)
+		}
+	}
+}
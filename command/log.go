@@ -0,0 +1,116 @@
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+
+	"github.com/timeglass/glass/billing"
+	"github.com/timeglass/glass/config"
+	daemon "github.com/timeglass/glass/glass-daemon"
+)
+
+type Log struct {
+	*command
+}
+
+func NewLog() *Log {
+	return &Log{newCommand()}
+}
+
+func (c *Log) Name() string {
+	return "log"
+}
+
+func (c *Log) Description() string {
+	return fmt.Sprintf("Lists the time that was recorded for each commit in the given revision range (e.g v0.5.0..HEAD, defaults to HEAD) that match the filters and paths (given after '--'), optionally grouped by author, day, week or branch with a subtotal for each group. Commits without time data are left out unless --include-empty is given. If billing rules are configured the billed time (and amount) is listed next to the measured time")
+}
+
+func (c *Log) Usage() string {
+	return "Report time measurements per commit"
+}
+
+func (c *Log) Flags() []cli.Flag {
+	return append(queryFlags(),
+		cli.StringFlag{Name: "group-by,g", Value: "", Usage: "group commits by 'author', 'day', 'week' or 'branch'"},
+		cli.StringFlag{Name: "format,f", Value: "table", Usage: "output format: 'table', 'csv', 'json' or 'markdown'"},
+		cli.BoolFlag{Name: "include-empty", Usage: "also list commits that have no time data"},
+	)
+}
+
+func (c *Log) Action() func(ctx *cli.Context) {
+	return c.command.Action(c.Run)
+}
+
+func (c *Log) Run(ctx *cli.Context) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
+	}
+
+	vc, err := getVCS(dir)
+	if err != nil {
+		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
+	}
+
+	sysdir, err := daemon.SystemTimeglassPath()
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to get system config path: {{err}}"), err)
+	}
+
+	conf, err := config.ReadConfig(vc.Root(), sysdir)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to read configuration: {{err}}"), err)
+	}
+
+	biller, err := billing.New(conf.Billing)
+	if err != nil {
+		return errwrap.Wrapf("Invalid billing configuration: {{err}}", err)
+	}
+
+	q := buildQuery(ctx)
+
+	//only name branches when they are shown, it is slow on long histories
+	q.Branches = ctx.String("group-by") == "branch" || ctx.String("format") == "csv" || ctx.String("format") == "json"
+	commits, err := vc.Log(q)
+	if err != nil {
+		return errwrap.Wrapf("Failed to list commits: {{err}}", err)
+	}
+
+	hashes := []string{}
+	for _, commit := range commits {
+		hashes = append(hashes, commit.Hash)
+	}
+
+	notes, err := vc.ShowAll(hashes)
+	if err != nil {
+		return errwrap.Wrapf("Failed to show time notes: {{err}}", err)
+	}
+
+	rows := []*reportRow{}
+	for _, commit := range commits {
+		row := &reportRow{Commit: commit}
+		if data, ok := notes[commit.Hash]; ok {
+			row.Time = data.Total()
+			row.HasData = true
+		} else if !ctx.Bool("include-empty") {
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	err = billReport(rows, biller)
+	if err != nil {
+		return err
+	}
+
+	groups, err := groupReport(rows, ctx.String("group-by"))
+	if err != nil {
+		return err
+	}
+
+	return writeReport(os.Stdout, ctx.String("format"), groups, biller)
+}
@@ -0,0 +1,73 @@
+package command
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timeglass/glass/billing"
+	"github.com/timeglass/glass/config"
+	"github.com/timeglass/glass/vcs"
+)
+
+var update = flag.Bool("update", false, "write the output of tests to their golden files")
+
+// compares the output with the golden file in testdata, use
+// 'go test -update' to write the golden file after a change
+func assertGolden(t *testing.T, name string, out []byte) {
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		assert.NoError(t, ioutil.WriteFile(path, out, 0644))
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, string(expected), string(out), name)
+	}
+}
+
+// commits as they are listed by the log command, the
+// second commit has no time data
+func logRows() []*reportRow {
+	day := time.Date(2015, 6, 1, 9, 30, 0, 0, time.UTC)
+	return []*reportRow{
+		{Commit: &vcs.Commit{Hash: "3f2a9c0b1d4e5f60718293a4b5c6d7e8f9a0b1c2", Date: day, Author: "alice@example.com", Branch: "master", Subject: "Fix login | logout"}, Time: 20 * time.Minute, HasData: true},
+		{Commit: &vcs.Commit{Hash: "8e7d6c5b4a39281706f5e4d3c2b1a09f8e7d6c5b", Date: day.Add(2 * time.Hour), Author: "bob@example.com", Branch: "master", Subject: "Update readme"}},
+		{Commit: &vcs.Commit{Hash: "1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d", Date: day.Add(26 * time.Hour), Author: "alice@example.com", Subject: "Add export, with \"columns\""}, Time: 95 * time.Minute, HasData: true},
+	}
+}
+
+func TestLogFormats(t *testing.T) {
+	billed := &config.Billing{Rounding: &config.Rounding{Scope: "commit", Mode: "up", Unit: config.Duration(15 * time.Minute)}, Rate: 80, Currency: "EUR"}
+	for _, c := range []struct {
+		name    string
+		groupBy string
+		billing *config.Billing
+	}{
+		{"plain", "", nil},
+		{"grouped", "author", billed},
+	} {
+		for format := range reportFormats {
+			b, err := billing.New(c.billing)
+			assert.NoError(t, err)
+
+			rows := logRows()
+			assert.NoError(t, billReport(rows, b))
+			groups, err := groupReport(rows, c.groupBy)
+			assert.NoError(t, err)
+
+			out := bytes.NewBuffer(nil)
+			assert.NoError(t, writeReport(out, format, groups, b))
+			assertGolden(t, "log_"+c.name+"."+format, out.Bytes())
+		}
+	}
+
+	_, err := groupReport(logRows(), "month")
+	assert.Error(t, err)
+	assert.Error(t, writeReport(ioutil.Discard, "xml", nil, nil))
+}
@@ -0,0 +1,64 @@
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+)
+
+type Notes struct {
+	*command
+}
+
+func NewNotes() *Notes {
+	return &Notes{newCommand()}
+}
+
+func (c *Notes) Name() string {
+	return "notes"
+}
+
+func (c *Notes) Description() string {
+	return fmt.Sprintf("Maintains the time data that is stored with commits. Provide the action as the first argument, currently only 'migrate' is supported: it rewrites all notes that were written by an older version of Timeglass into the current format, time of legacy notes is attributed to the commit author")
+}
+
+func (c *Notes) Usage() string {
+	return "Maintain time data stored in the repository"
+}
+
+func (c *Notes) Flags() []cli.Flag {
+	return []cli.Flag{}
+}
+
+func (c *Notes) Action() func(ctx *cli.Context) {
+	return c.command.Action(c.Run)
+}
+
+func (c *Notes) Run(ctx *cli.Context) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
+	}
+
+	vc, err := getVCS(dir)
+	if err != nil {
+		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
+	}
+
+	switch ctx.Args().First() {
+	case "migrate":
+		c.Println("Migrating notes to the current format...")
+		n, err := vc.MigrateNotes()
+		if err != nil {
+			return errwrap.Wrapf("Failed to migrate notes: {{err}}", err)
+		}
+
+		c.Printf("Migrated %d note(s)", n)
+	default:
+		return fmt.Errorf("Unknown action '%s', expected one of: migrate", ctx.Args().First())
+	}
+
+	return nil
+}
@@ -6,8 +6,6 @@ import (
 
 	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
 	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
-
-	"github.com/timeglass/glass/vcs"
 )
 
 type Pause struct {
@@ -23,7 +21,7 @@ func (c *Pause) Name() string {
 }
 
 func (c *Pause) Description() string {
-	return fmt.Sprintf("Pauses the timer, running 'glass start' or editing a file in the repository resumes the timer")
+	return fmt.Sprintf("Pauses the timer, running 'glass start' or editing a file in the repository resumes the timer. The timers of nested sub-projects are paused as well")
 }
 
 func (c *Pause) Usage() string {
@@ -44,16 +42,31 @@ func (c *Pause) Run(ctx *cli.Context) error {
 		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
 	}
 
-	vc, err := vcs.GetVCS(dir)
+	vc, err := getVCS(dir)
 	if err != nil {
 		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
 	}
 
 	c.Printf("Pausing timer...")
 
-	client := NewClient()
-	err = client.PauseTimer(vc.Root())
+	projects, err := projectDirs(getProject(vc, dir))
 	if err != nil {
+		return err
+	}
+
+	client := NewClient()
+	for i, project := range projects {
+		err = client.PauseTimer(project)
+		if err == nil {
+			continue
+		}
+
+		//nested sub-projects may not have been started
+		if i > 0 {
+			c.Printf("Failed to pause timer of sub-project '%s': %s", project, err)
+			continue
+		}
+
 		return errwrap.Wrapf(fmt.Sprintf("Failed to pause timer: {{err}}"), err)
 	}
 
@@ -44,7 +44,7 @@ func (c *Pull) Run(ctx *cli.Context) error {
 		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
 	}
 
-	vc, err := vcs.GetVCS(dir)
+	vc, err := getVCS(dir)
 	if err != nil {
 		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
 	}
@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
 	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
 	"github.com/timeglass/glass/_vendor/github.com/mattn/go-isatty"
 
+	"github.com/timeglass/glass/config"
+	daemon "github.com/timeglass/glass/glass-daemon"
 	"github.com/timeglass/glass/vcs"
 )
 
@@ -26,15 +29,23 @@ func (c *Punch) Name() string {
 }
 
 func (c *Punch) Description() string {
-	return fmt.Sprintf("Writes time to the metadata of the last commit, should be provided in the following format: 6h20m12s")
+	return fmt.Sprintf("Writes time to the metadata of a commit (HEAD by default), should be provided in the following format: 6h20m12s. By default the time replaces the entry of the author, use --add or --subtract to correct it instead. Each change is recorded as an audit line in the commit's time data. Time is registered for the (sub-)project of the current directory, with --timers the time of the timers of the project and its nested sub-projects is registered instead")
 }
 
 func (c *Punch) Usage() string {
-	return "Manually register time spent on the last commit"
+	return "Manually register time spent on a commit"
 }
 
 func (c *Punch) Flags() []cli.Flag {
-	return []cli.Flag{}
+	return []cli.Flag{
+		cli.StringFlag{Name: "commit,c", Value: "HEAD", Usage: "the commit to register time for, in any format the VCS understands"},
+		cli.BoolFlag{Name: "add", Usage: "add the time to what was already registered for the author"},
+		cli.BoolFlag{Name: "set", Usage: "replace the time registered for the author (default)"},
+		cli.BoolFlag{Name: "subtract", Usage: "subtract the time from what was already registered for the author"},
+		cli.StringFlag{Name: "author,a", Value: "", Usage: "email of the author the time is registered for, defaults to the current user"},
+		cli.StringFlag{Name: "message,m", Value: "", Usage: "a message that explains the change, it is stored with the audit line"},
+		cli.BoolFlag{Name: "timers", Usage: "register the time of the timers of the project and its sub-projects instead of a provided time"},
+	}
 }
 
 func (c *Punch) Action() func(ctx *cli.Context) {
@@ -47,8 +58,26 @@ func (c *Punch) Run(ctx *cli.Context) error {
 		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
 	}
 
+	mode := vcs.PunchSet
+	nmodes := 0
+	for _, m := range []vcs.PunchMode{vcs.PunchAdd, vcs.PunchSet, vcs.PunchSubtract} {
+		if ctx.Bool(string(m)) {
+			mode = m
+			nmodes++
+		}
+	}
+
+	if nmodes > 1 {
+		return fmt.Errorf("Please provide only one of --add, --set or --subtract")
+	}
+
+	if ctx.Bool("timers") {
+		return c.punchTimers(ctx, dir, mode)
+	}
+
 	var input string
-	if isatty.IsTerminal(os.Stdin.Fd()) {
+	piped := !isatty.IsTerminal(os.Stdin.Fd()) && ctx.Args().First() == ""
+	if !piped {
 		c.Println("Reading input from argument...")
 		input = ctx.Args().First()
 	} else {
@@ -64,19 +93,54 @@ func (c *Punch) Run(ctx *cli.Context) error {
 		return fmt.Errorf("Please provide the time you spent as the first argument")
 	}
 
-	t, err := time.ParseDuration(input)
+	t, err := time.ParseDuration(strings.TrimSpace(input))
 	if err != nil {
 		return errwrap.Wrapf(fmt.Sprintf("Failed to parse provided argument '%s' as a valid duration (e.g 1h2m10s): {{err}}", input), err)
 	}
 
+	//use --subtract to take time away
+	if t <= 0 {
+		return fmt.Errorf("Please provide a positive duration, got '%s'", strings.TrimSpace(input))
+	}
+
 	//write the vcs
-	vc, err := vcs.GetVCS(dir)
+	vc, err := getVCS(dir)
 	if err != nil {
 		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
 	}
 
-	c.Printf("Persisting %s to version control...", t)
-	err = vc.Persist(t)
+	sysdir, err := daemon.SystemTimeglassPath()
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to get system config path: {{err}}"), err)
+	}
+
+	project := getProject(vc, dir)
+	conf, err := config.ReadProjectConfig(vc.Root(), project, sysdir)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to read configuration: {{err}}"), err)
+	}
+
+	entry := &vcs.TimeEntry{
+		Author:  ctx.String("author"),
+		Project: projectName(vc, project),
+		Time:    t,
+		MBU:     time.Duration(conf.MBU),
+		Machine: vcs.MachineID(),
+	}
+
+	//time that is piped in (e.g by the post-commit hook) originates
+	//from the timer, ask it when the measured session started and ended
+	if piped {
+		timer, err := NewClient().ReadTimer(project)
+		if err == nil {
+			entry.Start = timer.SessionStart()
+			entry.End = timer.SessionEnd()
+		}
+	}
+
+	commit := ctx.String("commit")
+	c.Printf("Persisting %s (%s) for commit '%s' to version control...", t, mode, commit)
+	err = vc.Persist(commit, mode, entry, ctx.String("message"))
 	if err != nil {
 		return errwrap.Wrapf("Failed to log time into VCS: {{err}}", err)
 	}
@@ -84,3 +148,87 @@ func (c *Punch) Run(ctx *cli.Context) error {
 	c.Println("Done!")
 	return nil
 }
+
+// registers the time of the timer of the current project and of each
+// nested sub-project (e.g by the post-commit hook), every sub-project
+// gets an entry of its own using the mbu of its own configuration
+func (c *Punch) punchTimers(ctx *cli.Context, dir string, mode vcs.PunchMode) error {
+	vc, err := getVCS(dir)
+	if err != nil {
+		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
+	}
+
+	sysdir, err := daemon.SystemTimeglassPath()
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to get system config path: {{err}}"), err)
+	}
+
+	projects, err := projectDirs(getProject(vc, dir))
+	if err != nil {
+		return err
+	}
+
+	client := NewClient()
+	commit := ctx.String("commit")
+	timers := map[string]*daemon.Timer{}
+	for i, project := range projects {
+		timer, err := client.ReadTimer(project)
+		if err != nil {
+			if i == 0 {
+				return errwrap.Wrapf(fmt.Sprintf("Failed to fetch timer: {{err}}"), err)
+			}
+
+			c.Printf("Failed to fetch timer of sub-project '%s', skipping: %s", project, err)
+			continue
+		}
+
+		//nothing is punched (or reset by the hook) until held time is confirmed
+		if timer.Held() != "" {
+			return fmt.Errorf("The timer of '%s' was held because it %s, run 'glass status' to keep or discard that time and then 'glass punch --timers && glass reset' to register it", project, timer.Held())
+		}
+
+		timers[project] = timer
+	}
+
+	for i, project := range projects {
+		timer, ok := timers[project]
+		if !ok {
+			continue
+		}
+
+		//sub-projects that weren't worked on are left out
+		if i > 0 && timer.Time() == 0 && timer.OffHours() == 0 {
+			continue
+		}
+
+		conf, err := config.ReadProjectConfig(vc.Root(), project, sysdir)
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("Failed to read configuration of '%s': {{err}}", project), err)
+		}
+
+		entry := &vcs.TimeEntry{
+			Author:   ctx.String("author"),
+			Project:  projectName(vc, project),
+			Time:     timer.Time(),
+			MBU:      time.Duration(conf.MBU),
+			Machine:  vcs.MachineID(),
+			Start:    timer.SessionStart(),
+			End:      timer.SessionEnd(),
+			OffHours: timer.OffHours(),
+		}
+
+		if entry.OffHours > 0 {
+			c.Printf("Persisting %s (%s) of '%s' and %s outside of working hours for commit '%s' to version control...", entry.Time, mode, project, entry.OffHours, commit)
+		} else {
+			c.Printf("Persisting %s (%s) of '%s' for commit '%s' to version control...", entry.Time, mode, project, commit)
+		}
+
+		err = vc.Persist(commit, mode, entry, ctx.String("message"))
+		if err != nil {
+			return errwrap.Wrapf("Failed to log time into VCS: {{err}}", err)
+		}
+	}
+
+	c.Println("Done!")
+	return nil
+}
@@ -83,7 +83,7 @@ func (c *Push) Run(ctx *cli.Context) error {
 		}
 	}
 
-	vc, err := vcs.GetVCS(dir)
+	vc, err := getVCS(dir)
 	if err != nil {
 		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
 	}
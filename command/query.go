@@ -0,0 +1,85 @@
+package command
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+	"github.com/timeglass/glass/_vendor/github.com/mattn/go-isatty"
+
+	"github.com/timeglass/glass/vcs"
+)
+
+// flags that are shared by all commands that
+// select commits from the history
+func queryFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{Name: "since", Value: "", Usage: "only select commits more recent than a specific date (e.g '2 weeks ago')"},
+		cli.StringFlag{Name: "until", Value: "", Usage: "only select commits older than a specific date"},
+		cli.StringFlag{Name: "author", Value: "", Usage: "only select commits of authors that match the pattern"},
+		cli.BoolFlag{Name: "all", Usage: "select commits reachable from all refs instead of only HEAD"},
+	}
+}
+
+// builds a query from the arguments and flags, arguments after '--'
+// are considered paths. The flag parser swallows the terminator in
+// some positions so it is looked up in the raw arguments instead
+func buildQuery(ctx *cli.Context) *vcs.Query {
+	q := &vcs.Query{
+		Since:  ctx.String("since"),
+		Until:  ctx.String("until"),
+		Author: ctx.String("author"),
+		All:    ctx.Bool("all"),
+	}
+
+	for i, arg := range os.Args {
+		if arg == "--" {
+			q.Paths = os.Args[i+1:]
+			break
+		}
+	}
+
+	args := []string(ctx.Args())
+	args = args[:len(args)-len(q.Paths)]
+	for _, arg := range args {
+		if arg != "--" {
+			q.Revs = append(q.Revs, arg)
+		}
+	}
+
+	return q
+}
+
+// wether any of the revisions specifies a range
+// of commits, e.g: 'v0.5.0..HEAD' or '^master'
+func isRange(revs []string) bool {
+	for _, rev := range revs {
+		if strings.Contains(rev, "..") || strings.HasPrefix(rev, "^") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reads revisions from STDIN, one revision per line, if
+// no revisions were provided as arguments
+func readRevs(q *vcs.Query) error {
+	if len(q.Revs) > 0 || isatty.IsTerminal(os.Stdin.Fd()) {
+		return nil
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			q.Revs = append(q.Revs, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errwrap.Wrapf("Failed to read revisions from STDIN: {{err}}", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,383 @@
+package command
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/timeglass/glass/billing"
+	"github.com/timeglass/glass/vcs"
+)
+
+// a single commit with the time that was recorded for it
+type reportRow struct {
+	Commit  *vcs.Commit
+	Time    time.Duration
+	Billed  time.Duration
+	HasData bool
+}
+
+// commits that share the same key, e.g the same author
+type reportGroup struct {
+	Key    string
+	Rows   []*reportRow
+	Total  time.Duration
+	Billed time.Duration
+}
+
+var reportGroupings = map[string]func(r *reportRow) string{
+	"": func(r *reportRow) string { return "" },
+	"author": func(r *reportRow) string {
+		return r.Commit.Author
+	},
+	"day": func(r *reportRow) string {
+		return r.Commit.Date.Format("2006-01-02")
+	},
+	"week": func(r *reportRow) string {
+		y, w := r.Commit.Date.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", y, w)
+	},
+	"branch": func(r *reportRow) string {
+		if r.Commit.Branch == "" {
+			return "(unknown)"
+		}
+
+		return r.Commit.Branch
+	},
+}
+
+var reportFormats = map[string]func(w io.Writer, groups []*reportGroup, b *billing.Biller) error{
+	"table":    writeReportTable,
+	"csv":      writeReportCSV,
+	"json":     writeReportJSON,
+	"markdown": writeReportMarkdown,
+}
+
+// groups rows by the given grouping, groups are ordered
+// by the first row that was encountered for each
+func groupReport(rows []*reportRow, by string) ([]*reportGroup, error) {
+	keyfn, ok := reportGroupings[by]
+	if !ok {
+		return nil, fmt.Errorf("Unknown grouping '%s', expected one of: author, day, week or branch", by)
+	}
+
+	groups := []*reportGroup{}
+	idx := map[string]*reportGroup{}
+	for _, r := range rows {
+		key := keyfn(r)
+		g, ok := idx[key]
+		if !ok {
+			g = &reportGroup{Key: key}
+			idx[key] = g
+			groups = append(groups, g)
+		}
+
+		g.Rows = append(g.Rows, r)
+		g.Total += r.Time
+		g.Billed += r.Billed
+	}
+
+	return groups, nil
+}
+
+// sets the billed time of all rows that have time data
+func billReport(rows []*reportRow, b *billing.Biller) error {
+	items := []*billing.Item{}
+	billed := []*reportRow{}
+	for _, r := range rows {
+		if r.HasData {
+			items = append(items, &billing.Item{Date: r.Commit.Date, Time: r.Time})
+			billed = append(billed, r)
+		}
+	}
+
+	err := b.Apply(items)
+	if err != nil {
+		return err
+	}
+
+	for i, r := range billed {
+		r.Billed = items[i].Billed
+	}
+
+	return nil
+}
+
+func writeReport(w io.Writer, format string, groups []*reportGroup, b *billing.Biller) error {
+	fn, ok := reportFormats[format]
+	if !ok {
+		return fmt.Errorf("Unknown format '%s', expected one of: table, csv, json or markdown", format)
+	}
+
+	return fn(w, groups, b)
+}
+
+func reportTotal(groups []*reportGroup) (time.Duration, time.Duration) {
+	var total, billed time.Duration
+	for _, g := range groups {
+		total += g.Total
+		billed += g.Billed
+	}
+
+	return total, billed
+}
+
+// the extra cells that are shown for billed time, if
+// billing is configured, with the amount if a rate is set
+func reportBilling(b *billing.Biller, hasData bool, d time.Duration) []string {
+	cells := []string{}
+	if !b.Enabled() {
+		return cells
+	}
+
+	if !hasData {
+		cells = append(cells, "-")
+	} else {
+		cells = append(cells, d.String())
+	}
+
+	if b.HasRate() {
+		if !hasData {
+			cells = append(cells, "-")
+		} else {
+			cells = append(cells, b.Money(d))
+		}
+	}
+
+	return cells
+}
+
+func reportBillingHeaders(b *billing.Biller) []string {
+	headers := []string{}
+	if b.Enabled() {
+		headers = append(headers, "billed")
+	}
+
+	if b.HasRate() {
+		headers = append(headers, "amount")
+	}
+
+	return headers
+}
+
+func reportTime(r *reportRow) string {
+	if !r.HasData {
+		return "-"
+	}
+
+	return r.Time.String()
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+
+	return hash
+}
+
+func writeReportTable(w io.Writer, groups []*reportGroup, b *billing.Biller) error {
+	//every line has the same number of cells such
+	//that all groups are aligned in the same columns
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	line := func(cells ...string) {
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+
+	headers := []string{"COMMIT", "DATE", "AUTHOR", "SUBJECT", "TIME"}
+	for _, h := range reportBillingHeaders(b) {
+		headers = append(headers, strings.ToUpper(h))
+	}
+
+	empty := make([]string, len(headers))
+	line(headers...)
+	for _, g := range groups {
+		if g.Key != "" {
+			line(empty...)
+			line(append([]string{g.Key}, empty[1:]...)...)
+		}
+
+		for _, r := range g.Rows {
+			line(append([]string{shortHash(r.Commit.Hash), r.Commit.Date.Format("2006-01-02 15:04"), r.Commit.Author, r.Commit.Subject, reportTime(r)}, reportBilling(b, r.HasData, r.Billed)...)...)
+		}
+
+		if g.Key != "" {
+			line(append([]string{"", "", "", "subtotal", g.Total.String()}, reportBilling(b, true, g.Billed)...)...)
+		}
+	}
+
+	total, billed := reportTotal(groups)
+	line(empty...)
+	line(append([]string{"", "", "", "total", total.String()}, reportBilling(b, true, billed)...)...)
+	return tw.Flush()
+}
+
+func writeReportMarkdown(w io.Writer, groups []*reportGroup, b *billing.Biller) error {
+	escape := strings.NewReplacer("|", "\\|", "\n", " ")
+	bold := func(cells []string) []string {
+		for i, c := range cells {
+			cells[i] = "__" + c + "__"
+		}
+
+		return cells
+	}
+
+	headers, aligns := "", ""
+	for _, h := range reportBillingHeaders(b) {
+		headers += fmt.Sprintf(" %s |", strings.Title(h))
+		aligns += strings.Repeat("-", len(h)+1) + ":|"
+	}
+
+	for _, g := range groups {
+		if g.Key != "" {
+			fmt.Fprintf(w, "### %s\n\n", escape.Replace(g.Key))
+		}
+
+		fmt.Fprintf(w, "| Commit | Date | Author | Subject | Time |%s\n", headers)
+		fmt.Fprintf(w, "|--------|------|--------|---------|-----:|%s\n", aligns)
+		for _, r := range g.Rows {
+			cells := append([]string{shortHash(r.Commit.Hash), r.Commit.Date.Format("2006-01-02 15:04"), escape.Replace(r.Commit.Author), escape.Replace(r.Commit.Subject), reportTime(r)}, reportBilling(b, r.HasData, r.Billed)...)
+			fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+		}
+
+		if g.Key != "" {
+			cells := append([]string{"", "", "", "__Subtotal__", "__" + g.Total.String() + "__"}, bold(reportBilling(b, true, g.Billed))...)
+			fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+		}
+
+		fmt.Fprintln(w)
+	}
+
+	total, billed := reportTotal(groups)
+	extra := ""
+	if b.Enabled() {
+		extra = fmt.Sprintf(", billed: %s", strings.Join(reportBilling(b, true, billed), ", "))
+	}
+
+	_, err := fmt.Fprintf(w, "__Total: %s%s__\n", total, extra)
+	return err
+}
+
+// csv cells for billing, amounts are written without currency
+func reportBillingCSV(b *billing.Biller, d time.Duration) []string {
+	cells := []string{}
+	if b.Enabled() {
+		cells = append(cells, d.String(), fmt.Sprintf("%.0f", d.Seconds()))
+	}
+
+	if b.HasRate() {
+		cells = append(cells, fmt.Sprintf("%.2f", b.Amount(d)), b.Currency())
+	}
+
+	return cells
+}
+
+func writeReportCSV(w io.Writer, groups []*reportGroup, b *billing.Biller) error {
+	cw := csv.NewWriter(w)
+	header := []string{"type", "group", "commit", "date", "author", "branch", "subject", "time", "seconds"}
+	if b.Enabled() {
+		header = append(header, "billed", "billed_seconds")
+	}
+
+	if b.HasRate() {
+		header = append(header, "amount", "currency")
+	}
+
+	cw.Write(header)
+	for _, g := range groups {
+		for _, r := range g.Rows {
+			cw.Write(append([]string{"commit", g.Key, r.Commit.Hash, r.Commit.Date.Format(time.RFC3339), r.Commit.Author, r.Commit.Branch, r.Commit.Subject, reportTime(r), fmt.Sprintf("%.0f", r.Time.Seconds())}, reportBillingCSV(b, r.Billed)...))
+		}
+
+		if g.Key != "" {
+			cw.Write(append([]string{"subtotal", g.Key, "", "", "", "", "", g.Total.String(), fmt.Sprintf("%.0f", g.Total.Seconds())}, reportBillingCSV(b, g.Billed)...))
+		}
+	}
+
+	total, billed := reportTotal(groups)
+	cw.Write(append([]string{"total", "", "", "", "", "", "", total.String(), fmt.Sprintf("%.0f", total.Seconds())}, reportBillingCSV(b, billed)...))
+	cw.Flush()
+	return cw.Error()
+}
+
+type jsonReportCommit struct {
+	Hash    string  `json:"hash"`
+	Date    string  `json:"date"`
+	Author  string  `json:"author"`
+	Branch  string  `json:"branch"`
+	Subject string  `json:"subject"`
+	Time    *string `json:"time"`
+	Seconds float64 `json:"seconds"`
+	*jsonReportBilling
+}
+
+// billed time and amount, only present if billing is configured
+type jsonReportBilling struct {
+	Billed        string   `json:"billed"`
+	BilledSeconds float64  `json:"billed_seconds"`
+	Amount        *float64 `json:"amount,omitempty"`
+	Currency      string   `json:"currency,omitempty"`
+}
+
+func newJSONReportBilling(b *billing.Biller, d time.Duration) *jsonReportBilling {
+	if !b.Enabled() {
+		return nil
+	}
+
+	jb := &jsonReportBilling{Billed: d.String(), BilledSeconds: d.Seconds(), Currency: b.Currency()}
+	if b.HasRate() {
+		amount := b.Amount(d)
+		jb.Amount = &amount
+	}
+
+	return jb
+}
+
+type jsonReportGroup struct {
+	Key     string              `json:"key,omitempty"`
+	Commits []*jsonReportCommit `json:"commits"`
+	Total   string              `json:"total"`
+	Seconds float64             `json:"seconds"`
+	*jsonReportBilling
+}
+
+func writeReportJSON(w io.Writer, groups []*reportGroup, b *billing.Biller) error {
+	total, billed := reportTotal(groups)
+	data := struct {
+		Groups  []*jsonReportGroup `json:"groups"`
+		Total   string             `json:"total"`
+		Seconds float64            `json:"seconds"`
+		*jsonReportBilling
+	}{[]*jsonReportGroup{}, total.String(), total.Seconds(), newJSONReportBilling(b, billed)}
+
+	for _, g := range groups {
+		jg := &jsonReportGroup{Key: g.Key, Commits: []*jsonReportCommit{}, Total: g.Total.String(), Seconds: g.Total.Seconds(), jsonReportBilling: newJSONReportBilling(b, g.Billed)}
+		for _, r := range g.Rows {
+			jc := &jsonReportCommit{
+				Hash:    r.Commit.Hash,
+				Date:    r.Commit.Date.Format(time.RFC3339),
+				Author:  r.Commit.Author,
+				Branch:  r.Commit.Branch,
+				Subject: r.Commit.Subject,
+				Seconds: r.Time.Seconds(),
+			}
+
+			if r.HasData {
+				t := r.Time.String()
+				jc.Time = &t
+				jc.jsonReportBilling = newJSONReportBilling(b, r.Billed)
+			}
+
+			jg.Commits = append(jg.Commits, jc)
+		}
+
+		data.Groups = append(data.Groups, jg)
+	}
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(data)
+}
@@ -6,8 +6,6 @@ import (
 
 	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
 	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
-
-	"github.com/timeglass/glass/vcs"
 )
 
 type Reset struct {
@@ -23,7 +21,7 @@ func (c *Reset) Name() string {
 }
 
 func (c *Reset) Description() string {
-	return fmt.Sprintf("Allows for setting the timer of the current repository to 0, this will discard the current measurement without saving")
+	return fmt.Sprintf("Allows for setting the timer of the current repository to 0, this will discard the current measurement without saving. The timers of nested sub-projects are reset as well. Timers that were held after reaching a limit are only reset with --force, use 'glass status' to keep or discard their time instead")
 }
 
 func (c *Reset) Usage() string {
@@ -31,7 +29,9 @@ func (c *Reset) Usage() string {
 }
 
 func (c *Reset) Flags() []cli.Flag {
-	return []cli.Flag{}
+	return []cli.Flag{
+		cli.BoolFlag{Name: "force", Usage: "also reset timers that were held after reaching a limit, discarding their time"},
+	}
 }
 
 func (c *Reset) Action() func(ctx *cli.Context) {
@@ -44,16 +44,31 @@ func (c *Reset) Run(ctx *cli.Context) error {
 		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
 	}
 
-	vc, err := vcs.GetVCS(dir)
+	vc, err := getVCS(dir)
 	if err != nil {
 		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
 	}
 
 	c.Printf("Resetting timer to 0s...")
 
-	client := NewClient()
-	err = client.ResetTimer(vc.Root())
+	projects, err := projectDirs(getProject(vc, dir))
 	if err != nil {
+		return err
+	}
+
+	client := NewClient()
+	for i, project := range projects {
+		err = client.ResetTimer(project, ctx.Bool("force"))
+		if err == nil {
+			continue
+		}
+
+		//nested sub-projects may not have been started
+		if i > 0 {
+			c.Printf("Failed to reset timer of sub-project '%s': %s", project, err)
+			continue
+		}
+
 		return errwrap.Wrapf(fmt.Sprintf("Failed to reset timer: {{err}}"), err)
 	}
 
@@ -6,8 +6,6 @@ import (
 
 	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
 	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
-
-	"github.com/timeglass/glass/vcs"
 )
 
 type Start struct {
@@ -23,7 +21,7 @@ func (c *Start) Name() string {
 }
 
 func (c *Start) Description() string {
-	return fmt.Sprintf("Creates a new timer for the current repository, if it is currently paused the timer continues.")
+	return fmt.Sprintf("Creates a new timer for the current repository, if it is currently paused the timer continues. Sub-projects (directories with a timeglass.json of their own) get a timer of their own, started along with the project they are nested in.")
 }
 
 func (c *Start) Usage() string {
@@ -44,17 +42,30 @@ func (c *Start) Run(ctx *cli.Context) error {
 		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
 	}
 
-	vc, err := vcs.GetVCS(dir)
+	vc, err := getVCS(dir)
 	if err != nil {
 		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
 	}
 
-	c.Println("Starting timer...")
+	projects, err := projectDirs(getProject(vc, dir))
+	if err != nil {
+		return err
+	}
 
 	client := NewClient()
-	err = client.CreateTimer(vc.Root())
-	if err != nil {
-		return errwrap.Wrapf("Failed to create timer: {{err}}", err)
+	for _, project := range projects {
+
+		//nested sub-projects, worktrees and submodules have timers of their own
+		excludes, err := excludedDirs(vc, project)
+		if err != nil {
+			return err
+		}
+
+		c.Printf("Starting timer for '%s'...", project)
+		err = client.CreateTimer(project, vc.Root(), excludes)
+		if err != nil {
+			return errwrap.Wrapf("Failed to create timer: {{err}}", err)
+		}
 	}
 
 	c.Println("Timer started!")
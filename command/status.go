@@ -1,14 +1,16 @@
 package command
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
-	"text/template"
+	"time"
 
 	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
 	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+	"github.com/timeglass/glass/_vendor/github.com/mattn/go-isatty"
 
 	"github.com/timeglass/glass/config"
 	daemon "github.com/timeglass/glass/glass-daemon"
@@ -28,7 +30,7 @@ func (c *Status) Name() string {
 }
 
 func (c *Status) Description() string {
-	return fmt.Sprintf("Asks the deamon for general information and the specifics of the current timer, it allows for arbritary formatting of the current time measurement.")
+	return fmt.Sprintf("Asks the deamon for general information and the specifics of the current timer, it allows for arbritary formatting of the current time measurement. If estimates apply to the current branch the time spent on them is shown, with --warnings only the estimates that crossed a warning threshold are written to Stdout. The commit template of each nested sub-project that measured time is appended to that of the current project. When the timer was held after reaching a limit, the time of that session is only kept once confirmed: on the terminal or with --keep or --discard.")
 }
 
 func (c *Status) Usage() string {
@@ -39,6 +41,10 @@ func (c *Status) Flags() []cli.Flag {
 	return []cli.Flag{
 		cli.StringFlag{Name: "template,t", Value: "", Usage: "a template that allows for arbritary formatting of the time output"},
 		cli.BoolFlag{Name: "commit-template", Usage: "use the commit template from the configuration, this overwrites and custom template using -t"},
+		cli.BoolFlag{Name: "warnings", Usage: "only write a line for each estimate that crossed a warning threshold"},
+		cli.BoolFlag{Name: "trailer", Usage: "only write the commit message trailer with the time of the project and its sub-projects, if one is configured"},
+		cli.BoolFlag{Name: "keep", Usage: "keep the time of a held timer such that it can be punched"},
+		cli.BoolFlag{Name: "discard", Usage: "discard the time of the session that got a timer held"},
 	}
 }
 
@@ -46,13 +52,51 @@ func (c *Status) Action() func(ctx *cli.Context) {
 	return c.command.Action(c.Run)
 }
 
+// asks whether the time of a held timer should be kept, unless this
+// was answered with a flag. It returns whether the timer was released
+func (c *Status) release(ctx *cli.Context, client *Client, project string, timer *daemon.Timer) (bool, error) {
+	c.Printf("Timer was held because it %s, the %s of that session is not punched until it is confirmed", timer.Held(), timer.HeldTime())
+
+	//only ask when the output isn't used by a hook
+	keep, discard := ctx.Bool("keep"), ctx.Bool("discard")
+	plain := !ctx.Bool("warnings") && !ctx.Bool("trailer") && !ctx.Bool("commit-template") && ctx.String("template") == ""
+	if !keep && !discard && plain && isatty.IsTerminal(os.Stdin.Fd()) {
+		fmt.Fprintf(os.Stderr, "Keep the %s of that session? [y/n] ", timer.HeldTime())
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			keep = true
+		case "n", "no":
+			discard = true
+		}
+	}
+
+	if keep == discard {
+		c.Printf("Run 'glass status --keep' or 'glass status --discard' to confirm it")
+		return false, nil
+	}
+
+	err := client.ConfirmTimer(project, keep)
+	if err != nil {
+		return false, errwrap.Wrapf("Failed to confirm held time: {{err}}", err)
+	}
+
+	if keep {
+		c.Printf("Kept the %s, it is punched with the next commit or with 'glass punch --timers'", timer.HeldTime())
+	} else {
+		c.Printf("Discarded the %s", timer.HeldTime())
+	}
+
+	return true, nil
+}
+
 func (c *Status) Run(ctx *cli.Context) error {
 	dir, err := os.Getwd()
 	if err != nil {
 		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
 	}
 
-	vc, err := vcs.GetVCS(dir)
+	vc, err := getVCS(dir)
 	if err != nil {
 		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
 	}
@@ -62,7 +106,8 @@ func (c *Status) Run(ctx *cli.Context) error {
 		return errwrap.Wrapf(fmt.Sprintf("Failed to get system config path: {{err}}"), err)
 	}
 
-	conf, err := config.ReadConfig(vc.Root(), sysdir)
+	project := getProject(vc, dir)
+	conf, err := config.ReadProjectConfig(vc.Root(), project, sysdir)
 	if err != nil {
 		return errwrap.Wrapf(fmt.Sprintf("Failed to read configuration: {{err}}"), err)
 	}
@@ -84,21 +129,73 @@ func (c *Status) Run(ctx *cli.Context) error {
 
 	//fetch information on the timer specific to this directory
 	c.Printf("Fetching timer info...")
-	timer, err := client.ReadTimer(vc.Root())
+	timer, err := client.ReadTimer(project)
 	if err != nil {
 		return errwrap.Wrapf(fmt.Sprintf("Failed to fetch timer: {{err}}"), err)
 	}
 
+	if timer.Held() != "" {
+		released, err := c.release(ctx, client, project, timer)
+		if err != nil {
+			return err
+		}
+
+		if released {
+			timer, err = client.ReadTimer(project)
+			if err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("Failed to fetch timer: {{err}}"), err)
+			}
+		}
+	}
+
 	if reason := timer.HasFailed(); reason != "" {
 		c.Printf("Timer has failed: %s", reason)
 	} else {
-		if timer.IsPaused() {
+		if timer.Held() != "" {
+			c.Printf("Timer is currently: HELD")
+		} else if timer.IsPaused() && !conf.WorkingHours.Allows(time.Now()) {
+			c.Printf("Timer is currently: PAUSED (outside of working hours)")
+		} else if timer.IsPaused() {
 			c.Printf("Timer is currently: PAUSED")
 		} else {
 			c.Printf("Timer is currently: RUNNING")
 		}
 	}
 
+	if timer.OffHours() > 0 {
+		c.Printf("Outside of working hours: %s, this is not part of the time", timer.OffHours())
+	}
+
+	//update the budgets of the timer with recently committed time
+	//and show how much of each estimate has been spent
+	budgets, err := loadBudgets(vc, conf)
+	if err != nil {
+		c.Printf("Failed to load estimates: %s", err)
+	} else {
+		err = client.SetBudgets(project, budgets)
+		if err != nil {
+			c.Printf("Failed to update timer estimates: %s", err)
+		}
+	}
+
+	for _, b := range budgets {
+		c.Printf("Estimate: %s", b.Describe(timer.Time()))
+	}
+
+	if ctx.Bool("warnings") {
+		for _, b := range budgets {
+			if th := b.Crossed(timer.Time()); th > 0 {
+				fmt.Fprintf(os.Stdout, "# Timeglass: %s, over the %d%% warning threshold\n", b.Describe(timer.Time()), th)
+			}
+		}
+
+		return nil
+	}
+
+	if ctx.Bool("trailer") {
+		return c.trailer(vc, conf, project, timer.Time())
+	}
+
 	tmpls := ctx.String("template")
 	if ctx.Bool("commit-template") {
 		tmpls = conf.CommitMessage
@@ -106,22 +203,82 @@ func (c *Status) Run(ctx *cli.Context) error {
 
 	//we got some template specified
 	if tmpls != "" {
+		err = c.execute(tmpls, newMessageContext(vc, conf, timer, budgets))
+		if err != nil {
+			return err
+		}
+	} else {
+		//just print
+		c.Printf("Timer reads: %s", timer.Time())
+	}
+
+	//the commit message also mentions time spent on nested
+	//sub-projects, each using its own commit template
+	if !ctx.Bool("commit-template") {
+		return nil
+	}
+
+	subs, err := config.FindProjects(project)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		subtimer, err := client.ReadTimer(sub)
+		if err != nil || subtimer.Time() == 0 {
+			continue
+		}
 
-		//parse temlate and only report error if we're talking to a human
-		tmpl, err := template.New("commit-msg").Parse(tmpls)
+		subconf, err := config.ReadProjectConfig(vc.Root(), sub, sysdir)
 		if err != nil {
-			return errwrap.Wrapf(fmt.Sprintf("Failed to parse commit_message: '%s' in configuration as a text/template: {{err}}", conf.CommitMessage), err)
+			return errwrap.Wrapf(fmt.Sprintf("Failed to read configuration of sub-project '%s': {{err}}", sub), err)
 		}
 
-		//execute template and write to stdout
-		err = tmpl.Execute(os.Stdout, timer.Time())
+		err = c.execute(subconf.CommitMessage, newMessageContext(vc, subconf, subtimer, budgets))
 		if err != nil {
-			return errwrap.Wrapf(fmt.Sprintf("Failed to execute commit_message: template for time '%s': {{err}}", timer.Time()), err)
+			return err
 		}
+	}
 
-	} else {
-		//just print
-		c.Printf("Timer reads: %s", timer.Time())
+	return nil
+}
+
+// writes the trailer that records the time of the project and that
+// of its nested sub-projects, as they are all punched on commit
+func (c *Status) trailer(vc vcs.VCS, conf *config.Config, project string, t time.Duration) error {
+	if conf.Trailer == "" {
+		return nil
+	}
+
+	subs, err := config.FindProjects(project)
+	if err != nil {
+		return err
+	}
+
+	client := NewClient()
+	for _, sub := range subs {
+		subtimer, err := client.ReadTimer(sub)
+		if err == nil {
+			t += subtimer.Time()
+		}
+	}
+
+	fmt.Fprintln(os.Stdout, vcs.FormatTrailer(conf.Trailer, t))
+	return nil
+}
+
+func (c *Status) execute(tmpls string, mctx *MessageContext) error {
+
+	//parse temlate and only report error if we're talking to a human
+	tmpl, err := parseMessageTemplate(tmpls)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to parse commit_message: '%s' in configuration as a text/template: {{err}}", tmpls), err)
+	}
+
+	//execute template and write to stdout
+	err = tmpl.Execute(os.Stdout, mctx)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to execute commit_message: template for time '%s': {{err}}", mctx.Time), err)
 	}
 
 	return nil
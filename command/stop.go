@@ -6,8 +6,6 @@ import (
 
 	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
 	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
-
-	"github.com/timeglass/glass/vcs"
 )
 
 type Stop struct {
@@ -23,7 +21,7 @@ func (c *Stop) Name() string {
 }
 
 func (c *Stop) Description() string {
-	return fmt.Sprintf("Timer for the current repository is removed and any measurements are discarde without being saved")
+	return fmt.Sprintf("Timer for the current repository is removed and any measurements are discarde without being saved, the timers of nested sub-projects are removed as well")
 }
 
 func (c *Stop) Usage() string {
@@ -44,16 +42,31 @@ func (c *Stop) Run(ctx *cli.Context) error {
 		return errwrap.Wrapf("Failed to fetch current working dir: {{err}}", err)
 	}
 
-	vc, err := vcs.GetVCS(dir)
+	vc, err := getVCS(dir)
 	if err != nil {
 		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
 	}
 
 	c.Println("Deleting timer...")
 
-	client := NewClient()
-	err = client.DeleteTimer(vc.Root())
+	projects, err := projectDirs(getProject(vc, dir))
 	if err != nil {
+		return err
+	}
+
+	client := NewClient()
+	for i, project := range projects {
+		err = client.DeleteTimer(project)
+		if err == nil {
+			continue
+		}
+
+		//nested sub-projects may not have been started
+		if i > 0 {
+			c.Printf("Failed to delete timer of sub-project '%s': %s", project, err)
+			continue
+		}
+
 		return errwrap.Wrapf(fmt.Sprintf("Failed to delete timer: {{err}}"), err)
 	}
 
@@ -1,16 +1,17 @@
 package command
 
 import (
-	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/timeglass/glass/_vendor/github.com/codegangsta/cli"
 	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
-	"github.com/timeglass/glass/_vendor/github.com/mattn/go-isatty"
 
-	"github.com/timeglass/glass/vcs"
+	"github.com/timeglass/glass/billing"
+	"github.com/timeglass/glass/config"
+	daemon "github.com/timeglass/glass/glass-daemon"
 )
 
 type Sum struct {
@@ -26,7 +27,7 @@ func (c *Sum) Name() string {
 }
 
 func (c *Sum) Description() string {
-	return fmt.Sprintf("Reads time data for a list of commits provided to the command over STDIN. It expects one commit per line and the can be specified in any format that the underlying VCS accepts (refs, hashes, short hashes etc)")
+	return fmt.Sprintf("Reads time data for a selection of commits and adds it together. Commits can be provided as arguments or over STDIN (one per line) in any format that the underlying VCS accepts (refs, hashes, short hashes etc). When a revision range is given (e.g v0.5.0..HEAD), or no commits at all, all commits in the range are selected instead. Selections can be narrowed down further with filters and paths (given after '--'). If billing rules are configured the billed time is shown instead, followed by the amount if an hourly rate is set")
 }
 
 func (c *Sum) Usage() string {
@@ -34,7 +35,11 @@ func (c *Sum) Usage() string {
 }
 
 func (c *Sum) Flags() []cli.Flag {
-	return []cli.Flag{}
+	return append(queryFlags(),
+		cli.BoolFlag{Name: "json", Usage: "output the total and number of commits as JSON"},
+		cli.BoolFlag{Name: "hours", Usage: "output the total as a decimal number of hours"},
+		cli.BoolFlag{Name: "measured", Usage: "ignore the billing rules and output the measured time"},
+	)
 }
 
 func (c *Sum) Action() func(ctx *cli.Context) {
@@ -48,52 +53,114 @@ func (c *Sum) Run(ctx *cli.Context) error {
 	}
 
 	//write the vcs
-	vc, err := vcs.GetVCS(dir)
+	vc, err := getVCS(dir)
 	if err != nil {
 		return errwrap.Wrapf("Failed to setup VCS: {{err}}", err)
 	}
 
-	//retrieve commits through piped stdin or arguments
-	commits := []string{}
-	if !isatty.IsTerminal(os.Stdin.Fd()) {
-		scanner := bufio.NewScanner(os.Stdin)
-		for scanner.Scan() {
-			commits = append(commits, scanner.Text())
-		}
-		if err := scanner.Err(); err != nil {
-			c.Println(err)
-		}
-	} else {
-		if ctx.Args().First() != "" {
-			commits = append(commits, ctx.Args().First())
-		}
-		commits = append(commits, ctx.Args().Tail()...)
+	sysdir, err := daemon.SystemTimeglassPath()
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to get system config path: {{err}}"), err)
+	}
+
+	conf, err := config.ReadConfig(vc.Root(), sysdir)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to read configuration: {{err}}"), err)
+	}
+
+	if ctx.Bool("measured") {
+		conf.Billing = nil
+	}
+
+	biller, err := billing.New(conf.Billing)
+	if err != nil {
+		return errwrap.Wrapf("Invalid billing configuration: {{err}}", err)
+	}
+
+	//retrieve commits through arguments or piped stdin, only
+	//walk the history if a range was requested explicitely
+	q := buildQuery(ctx)
+	err = readRevs(q)
+	if err != nil {
+		return err
 	}
 
-	if len(commits) == 0 {
-		return fmt.Errorf("Please provide at least one commit through STDIN or as an argument.")
+	q.NoWalk = len(q.Revs) > 0 && !isRange(q.Revs)
+	commits, err := vc.Log(q)
+	if err != nil {
+		return errwrap.Wrapf("Failed to select commits: {{err}}", err)
 	}
 
-	//map time data from the vcs
-	list := []vcs.TimeData{}
+	//map time data from the vcs, commits without time are counted below
+	hashes := []string{}
 	for _, c := range commits {
-		data, err := vc.Show(c)
-		if err != nil {
-			if err == vcs.ErrNoCommitTimeData {
-				//ignore if a commit has no time attached
-				continue
-			}
-
-			return errwrap.Wrapf(fmt.Sprintf("Failed to show time notes for '%s': {{err}}", c), err)
-		}
+		hashes = append(hashes, c.Hash)
+	}
+
+	notes, err := vc.ShowAll(hashes)
+	if err != nil {
+		return errwrap.Wrapf("Failed to show time notes: {{err}}", err)
+	}
 
-		list = append(list, data)
+	//time outside of working hours is never billed, it is only reported
+	items := []*billing.Item{}
+	var offHours time.Duration
+	for _, c := range commits {
+		if data, ok := notes[c.Hash]; ok {
+			items = append(items, &billing.Item{Date: c.Date, Time: data.Total()})
+			offHours += data.OffHours()
+		}
 	}
 
 	//reduce to output
-	var total time.Duration
-	for _, data := range list {
-		total += data.Total()
+	err = biller.Apply(items)
+	if err != nil {
+		return err
+	}
+
+	var measured, total time.Duration
+	for _, it := range items {
+		measured += it.Time
+		total += it.Billed
+	}
+
+	missing := len(commits) - len(items)
+	if missing > 0 {
+		c.Printf("%d out of %d commit(s) had no time data", missing, len(commits))
+	}
+
+	if offHours > 0 {
+		c.Printf("another %s was measured outside of working hours and is not included", offHours)
+	}
+
+	if ctx.Bool("json") {
+		data := map[string]interface{}{
+			"total":             total.String(),
+			"seconds":           total.Seconds(),
+			"hours":             total.Hours(),
+			"measured":          measured.String(),
+			"off_hours":         offHours.String(),
+			"commits":           len(commits),
+			"commits_with_time": len(items),
+			"commits_no_time":   missing,
+		}
+
+		if biller.HasRate() {
+			data["amount"] = biller.Amount(total)
+			data["currency"] = biller.Currency()
+		}
+
+		return json.NewEncoder(os.Stdout).Encode(data)
+	}
+
+	if ctx.Bool("hours") {
+		fmt.Fprintf(os.Stdout, "%.2f\n", total.Hours())
+		return nil
+	}
+
+	if biller.HasRate() {
+		fmt.Fprintf(os.Stdout, "%s\t%s\n", total, biller.Money(total))
+		return nil
 	}
 
 	fmt.Fprintln(os.Stdout, total)
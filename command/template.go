@@ -0,0 +1,186 @@
+package command
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/timeglass/glass/billing"
+	"github.com/timeglass/glass/config"
+	daemon "github.com/timeglass/glass/glass-daemon"
+	"github.com/timeglass/glass/vcs"
+)
+
+// MessageContext is what the commit message template is executed with,
+// it embeds the measured time such that '{{.}}' still writes the time
+// and methods of the duration (e.g: '{{.Minutes}}') can still be used
+type MessageContext struct {
+	time.Duration
+
+	Time     time.Duration
+	Rounded  time.Duration
+	Hours    float64
+	Branch   string
+	Issues   []string
+	Estimate *EstimateContext
+	Sessions int
+}
+
+// EstimateContext describes the first estimate that applies to the
+// current branch, the time spent includes the time on the timer
+type EstimateContext struct {
+	Name       string
+	Estimate   time.Duration
+	Spent      time.Duration
+	Remaining  time.Duration
+	Percentage int
+}
+
+// builds the context of the commit message template, anything that can't
+// be determined (e.g: a detached HEAD has no branch) is left empty
+func newMessageContext(vc vcs.VCS, conf *config.Config, timer *daemon.Timer, budgets []*daemon.Budget) *MessageContext {
+	t := timer.Time()
+	ctx := &MessageContext{
+		Duration: t,
+		Time:     t,
+		Rounded:  t,
+		Hours:    t.Hours(),
+		Issues:   []string{},
+		Sessions: timer.Sessions(),
+	}
+
+	if conf.Billing != nil && conf.Billing.Rounding != nil {
+		rounded, err := billing.Round(t, time.Duration(conf.Billing.Rounding.Unit), conf.Billing.Rounding.Mode)
+		if err == nil {
+			ctx.Rounded = rounded
+		}
+	}
+
+	ctx.Branch, _ = vc.CurrentBranch()
+	if conf.Issues != nil && ctx.Branch != "" {
+		re, err := regexp.Compile(conf.Issues.Pattern)
+		if err == nil {
+			ctx.Issues = issueKeys(re, &vcs.Commit{Branch: ctx.Branch})
+		}
+	}
+
+	if len(budgets) > 0 {
+		b := budgets[0]
+		ctx.Estimate = &EstimateContext{
+			Name:       b.Name,
+			Estimate:   b.Estimate,
+			Spent:      b.Spent + t,
+			Remaining:  b.Estimate - b.Spent - t,
+			Percentage: b.Percentage(t),
+		}
+	}
+
+	return ctx
+}
+
+// converts the argument of a template function to a duration, this
+// can be the context itself, a duration or a string such as "15m"
+func toDuration(v interface{}) (time.Duration, error) {
+	switch d := v.(type) {
+	case *MessageContext:
+		return d.Time, nil
+	case MessageContext:
+		return d.Time, nil
+	case time.Duration:
+		return d, nil
+	case string:
+		return time.ParseDuration(d)
+	case int:
+		return time.Duration(d), nil
+	}
+
+	return 0, fmt.Errorf("Expected a duration, got '%v'", v)
+}
+
+var messageFuncs = template.FuncMap{
+
+	//rounds to a multiple of the unit, e.g: {{round . "15m"}} or {{round . "15m" "up"}}
+	"round": func(v, unit interface{}, mode ...string) (time.Duration, error) {
+		d, err := toDuration(v)
+		if err != nil {
+			return 0, err
+		}
+
+		u, err := toDuration(unit)
+		if err != nil {
+			return 0, err
+		}
+
+		m := "nearest"
+		if len(mode) > 0 {
+			m = mode[0]
+		}
+
+		return billing.Round(d, u, m)
+	},
+
+	//decimal hours with two decimals, e.g: 1.25
+	"hours": func(v interface{}) (string, error) {
+		d, err := toDuration(v)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("%.2f", d.Hours()), nil
+	},
+
+	//written out in words, e.g: 1 hour 5 minutes
+	"humanize": func(v interface{}) (string, error) {
+		d, err := toDuration(v)
+		if err != nil {
+			return "", err
+		}
+
+		return humanize(d), nil
+	},
+
+	//hours and minutes as on a clock, e.g: 1:05
+	"clock": func(v interface{}) (string, error) {
+		d, err := toDuration(v)
+		if err != nil {
+			return "", err
+		}
+
+		m := int64(math.Floor(d.Minutes()))
+		return fmt.Sprintf("%d:%02d", m/60, m%60), nil
+	},
+}
+
+func humanize(d time.Duration) string {
+	if d < time.Minute {
+		return "less than a minute"
+	}
+
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+
+	h, m := int64(d.Hours()), int64(d.Minutes())%60
+	parts := []string{}
+	if h > 0 {
+		parts = append(parts, plural(h, "hour"))
+	}
+
+	if m > 0 {
+		parts = append(parts, plural(m, "minute"))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// parses a commit message template with the helper functions
+func parseMessageTemplate(tmpls string) (*template.Template, error) {
+	return template.New("commit-msg").Funcs(messageFuncs).Parse(tmpls)
+}
@@ -0,0 +1,121 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timeglass/glass/config"
+	daemon "github.com/timeglass/glass/glass-daemon"
+	"github.com/timeglass/glass/vcs"
+)
+
+// a repository that is only asked for its branch
+type branchVCS struct {
+	vcs.VCS
+	branch string
+}
+
+func (v *branchVCS) CurrentBranch() (string, error) {
+	if v.branch == "" {
+		return "", errors.New("detached HEAD")
+	}
+
+	return v.branch, nil
+}
+
+// a timer as it is read from the background service
+func messageTimer(t *testing.T, d time.Duration, sessions int) *daemon.Timer {
+	timer := &daemon.Timer{}
+	data, err := json.Marshal(map[string]interface{}{"time": d, "sessions": sessions})
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, timer))
+	return timer
+}
+
+func executeMessage(t *testing.T, tmpl string, ctx *MessageContext) (string, error) {
+	tm, err := parseMessageTemplate(tmpl)
+	if !assert.NoError(t, err, tmpl) {
+		return "", err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = tm.Execute(buf, ctx)
+	return buf.String(), err
+}
+
+func TestMessageContext(t *testing.T) {
+	billed := *config.DefaultConfig
+	billed.Billing = &config.Billing{Rounding: &config.Rounding{Mode: "up", Unit: config.Duration(15 * time.Minute)}}
+	noIssues := *config.DefaultConfig
+	noIssues.Issues = nil
+
+	for _, c := range []struct {
+		conf     *config.Config
+		branch   string
+		budgets  []*daemon.Budget
+		tmpl     string
+		expected string
+	}{
+		//the template of older versions still writes the time
+		{config.DefaultConfig, "master", nil, " [{{.}}]", " [1h5m0s]"},
+		{config.DefaultConfig, "master", nil, "{{.Minutes}}", "65"},
+		{config.DefaultConfig, "master", nil, "{{.Time}} {{.Rounded}} {{.Hours}} {{.Sessions}}", "1h5m0s 1h5m0s 1.0833333333333333 2"},
+		{&billed, "master", nil, "{{.Rounded}}", "1h15m0s"},
+
+		//issue keys are taken from the branch when configured
+		{config.DefaultConfig, "feature/PROJ-12-login", nil, "{{.Branch}} {{.Issues}}", "feature/PROJ-12-login [PROJ-12]"},
+		{&noIssues, "feature/PROJ-12-login", nil, "{{.Issues}}", "[]"},
+		{config.DefaultConfig, "", nil, "{{.Branch}}{{.Issues}}", "[]"},
+
+		//the first budget is the estimate
+		{config.DefaultConfig, "master", nil, "{{if .Estimate}}{{.Estimate.Name}}{{end}}", ""},
+		{config.DefaultConfig, "login", []*daemon.Budget{{Name: "login", Estimate: 2 * time.Hour, Spent: 25 * time.Minute}, {Name: "other"}}, "{{.Estimate.Name}} {{.Estimate.Spent}} {{.Estimate.Remaining}} {{.Estimate.Percentage}}%", "login 1h30m0s 30m0s 75%"},
+	} {
+		ctx := newMessageContext(&branchVCS{branch: c.branch}, c.conf, messageTimer(t, 65*time.Minute, 2), c.budgets)
+		out, err := executeMessage(t, c.tmpl, ctx)
+		assert.NoError(t, err, c.tmpl)
+		assert.Equal(t, c.expected, out, c.tmpl)
+	}
+}
+
+func TestMessageFuncs(t *testing.T) {
+	ctx := &MessageContext{Duration: 65 * time.Minute, Time: 65 * time.Minute}
+	for tmpl, expected := range map[string]string{
+		`{{round . "15m"}}`:              "1h0m0s",
+		`{{round . "15m" "up"}}`:         "1h15m0s",
+		`{{round . "15m" "down"}}`:       "1h0m0s",
+		`{{round .Time "1h"}}`:           "1h0m0s",
+		`{{round "50m" "15m"}}`:          "45m0s",
+		`{{hours .}}`:                    "1.08",
+		`{{hours (round . "30m" "up")}}`: "1.50",
+		`{{hours "90s"}}`:                "0.03",
+		`{{humanize .}}`:                 "1 hour 5 minutes",
+		`{{humanize "2h1m"}}`:            "2 hours 1 minute",
+		`{{humanize "3h"}}`:              "3 hours",
+		`{{humanize "59s"}}`:             "less than a minute",
+		`{{clock .}}`:                    "1:05",
+		`{{clock "59s"}}`:                "0:00",
+		`{{clock "26h3m"}}`:              "26:03",
+	} {
+		out, err := executeMessage(t, tmpl, ctx)
+		assert.NoError(t, err, tmpl)
+		assert.Equal(t, expected, out, tmpl)
+	}
+
+	for tmpl, expected := range map[string]string{
+		`{{round . "15m" "sideways"}}`: "sideways",
+		`{{round . "a while"}}`:        "a while",
+		`{{hours .Branch}}`:            "invalid duration",
+		`{{clock .Issues}}`:            "Expected a duration",
+	} {
+		_, err := executeMessage(t, tmpl, ctx)
+		if assert.Error(t, err, tmpl) {
+			assert.Contains(t, err.Error(), expected, tmpl)
+		}
+	}
+}
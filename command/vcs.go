@@ -0,0 +1,152 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+
+	"github.com/timeglass/glass/config"
+	daemon "github.com/timeglass/glass/glass-daemon"
+	"github.com/timeglass/glass/vcs"
+)
+
+// returns the VCS for the given directory, a submodule is rolled up
+// into its superproject when the superproject is configured to do so
+// such that both share the timer, hooks and time data of the latter
+func getVCS(dir string) (vcs.VCS, error) {
+	return resolveVCS(dir, true)
+}
+
+// returns the VCS of the given directory itself, a submodule
+// is never rolled up into its superproject
+func getOwnVCS(dir string) (vcs.VCS, error) {
+	return resolveVCS(dir, false)
+}
+
+func resolveVCS(dir string, rollup bool) (vcs.VCS, error) {
+	vc, err := vcs.GetVCS(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	g, ok := vc.(*vcs.Git)
+	if !ok {
+		return vc, nil
+	}
+
+	super, err := g.Superproject()
+	if rollup && err == nil && super != "" {
+		conf, err := readRootConfig(super)
+		if err != nil {
+			return nil, err
+		}
+
+		if conf.Submodules == "rollup" {
+			return getVCS(super)
+		}
+	}
+
+	//time that was recorded in commit messages is read
+	//using the trailer key the repository is configured with
+	conf, err := readRootConfig(g.Root())
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.Trailer != "" {
+		g.SetTrailer(conf.Trailer)
+	}
+
+	return vc, nil
+}
+
+// reads the configuration of the root of a repository and
+// validates the options that apply to the repository as a whole
+func readRootConfig(dir string) (*config.Config, error) {
+	sysdir, err := daemon.SystemTimeglassPath()
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to get system config path: {{err}}"), err)
+	}
+
+	conf, err := config.ReadConfig(dir, sysdir)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to read configuration: {{err}}"), err)
+	}
+
+	if conf.Submodules != "independent" && conf.Submodules != "rollup" {
+		return nil, fmt.Errorf("Unknown value '%s' for 'submodules' in the configuration of '%s', expected 'independent' or 'rollup'", conf.Submodules, dir)
+	}
+
+	if strings.ContainsAny(conf.Trailer, ": \t\r\n") {
+		return nil, fmt.Errorf("Invalid value '%s' for 'trailer' in the configuration of '%s', expected a single word such as 'Time-Spent'", conf.Trailer, dir)
+	}
+
+	return conf, nil
+}
+
+// returns the directory of the (sub-)project the given directory
+// belongs to, this is the root of the VCS for most repositories
+func getProject(vc vcs.VCS, dir string) string {
+	return config.FindProject(vc.Root(), dir)
+}
+
+// returns the directory of the project followed by the directories
+// of all sub-projects that are nested in it, each has its own timer
+func projectDirs(project string) ([]string, error) {
+	subs, err := config.FindProjects(project)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{project}, subs...), nil
+}
+
+// returns the name of a (sub-)project as it is recorded with
+// the time data: its path relative to the root of the VCS
+func projectName(vc vcs.VCS, project string) string {
+	rel, err := filepath.Rel(vc.Root(), project)
+	if err != nil || rel == "." {
+		return ""
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// lists the directories in the project that are tracked by timers
+// of their own and are ignored by the timer of the project: nested
+// sub-projects and the worktrees and submodules of the repository
+func excludedDirs(vc vcs.VCS, project string) ([]string, error) {
+	dirs, err := config.FindProjects(project)
+	if err != nil {
+		return nil, err
+	}
+
+	g, ok := vc.(*vcs.Git)
+	if !ok {
+		return dirs, nil
+	}
+
+	conf, err := readRootConfig(vc.Root())
+	if err != nil {
+		return nil, err
+	}
+
+	worktrees, err := g.Worktrees()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs = append(dirs, worktrees...)
+	if conf.Submodules == "independent" {
+		submodules, err := g.Submodules()
+		if err != nil {
+			return nil, err
+		}
+
+		dirs = append(dirs, submodules...)
+	}
+
+	return dirs, nil
+}
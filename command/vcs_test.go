@@ -0,0 +1,70 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timeglass/glass/config"
+)
+
+// creates a scratch repository with a single commit
+func setupRepo(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "glass_command")
+	assert.NoError(t, err)
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.email", "glass@example.com"},
+		{"config", "user.name", "Glass"},
+		{"commit", "-q", "--allow-empty", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %s, %s", args, err, out)
+		}
+	}
+
+	//resolve symlinks (e.g: of the temp dir on OSX) like git does
+	dir, err = filepath.EvalSymlinks(dir)
+	assert.NoError(t, err)
+	return dir
+}
+
+func TestGetVCSRollup(t *testing.T) {
+	super := setupRepo(t)
+	defer os.RemoveAll(super)
+	lib := setupRepo(t)
+	defer os.RemoveAll(lib)
+
+	cmd := exec.Command("git", "-c", "protocol.file.allow=always", "submodule", "--quiet", "add", lib, "lib")
+	cmd.Dir = super
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+
+	sub := filepath.Join(super, "lib")
+	for _, c := range []struct {
+		submodules string
+		expected   string
+	}{
+		{"independent", sub},
+		{"rollup", super},
+	} {
+		assert.NoError(t, ioutil.WriteFile(config.Path(super), []byte(`{"submodules": "`+c.submodules+`"}`), 0644))
+
+		vc, err := getVCS(sub)
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, vc.Root(), c.submodules)
+
+		//the submodule itself can always be resolved, e.g: to deinit it
+		own, err := getOwnVCS(sub)
+		assert.NoError(t, err)
+		assert.Equal(t, sub, own.Root(), c.submodules)
+	}
+}
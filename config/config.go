@@ -1,28 +1,37 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
-	"github.com/timeglass/glass/_vendor/github.com/imdario/mergo"
 )
 
 var confFilename = "timeglass.json"
 
+// Path returns the path of the configuration file in dir
+func Path(dir string) string {
+	return filepath.Join(dir, confFilename)
+}
+
 type MBU time.Duration
 
 func (m MBU) String() string { return time.Duration(m).String() }
 
+func (m MBU) MarshalJSON() ([]byte, error) { return json.Marshal(m.String()) }
+
 func (t *MBU) UnmarshalJSON(data []byte) error {
 	raw, err := strconv.Unquote(string(data))
 	if err != nil {
-		return err
+		return fmt.Errorf("expected a duration such as \"1h15m\" but got %s", data)
 	}
 
 	parsed, err := time.ParseDuration(raw)
@@ -30,63 +39,543 @@ func (t *MBU) UnmarshalJSON(data []byte) error {
 		return errwrap.Wrapf("Failed to parse duration: {{err}}", err)
 	}
 
+	//the timer measures in units of the mbu, it can't be zero
+	if parsed <= 0 {
+		return fmt.Errorf("expected a positive duration but got %s", raw)
+	}
+
 	*t = MBU(parsed)
 	return nil
 }
 
+// Duration is a time.Duration that is written
+// in a human readable format, e.g: "1h15m"
+type Duration time.Duration
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+func (d Duration) MarshalJSON() ([]byte, error) { return json.Marshal(d.String()) }
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	raw, err := strconv.Unquote(string(data))
+	if err != nil {
+		return fmt.Errorf("expected a duration such as \"1h15m\" but got %s", data)
+	}
+
+	parsed, err := time.ParseDuration(raw)
+	if err != nil {
+		return errwrap.Wrapf("Failed to parse duration: {{err}}", err)
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// Configures a single export format, see: `glass export`
+type ExportConfig struct {
+	Columns   []string `json:"columns"`
+	Timezone  string   `json:"timezone"`
+	Round     Duration `json:"round"`
+	RoundMode string   `json:"round_mode"`
+	Project   string   `json:"project"`
+	Client    string   `json:"client"`
+	Task      string   `json:"task"`
+}
+
+// Determines how measured time is rounded before it is billed
+type Rounding struct {
+	Scope string   `json:"scope"`
+	Mode  string   `json:"mode"`
+	Unit  Duration `json:"unit"`
+}
+
+// Configures how time is reported for billing
+type Billing struct {
+	Rounding *Rounding `json:"rounding"`
+	Minimum  Duration  `json:"minimum"`
+	Rate     float64   `json:"rate"`
+	Currency string    `json:"currency"`
+}
+
+// Limits guard against timers that keep running without a break,
+// e.g: on the activity of a watcher process. Zero disables a limit
+type Limits struct {
+	Session Duration `json:"session"`
+	Daily   Duration `json:"daily"`
+}
+
+// Determines how issue keys are found in branch
+// names and commit messages, see: `glass issues`
+type IssuesConfig struct {
+	Pattern string `json:"pattern"`
+	Split   string `json:"split"`
+}
+
 var DefaultConfig = &Config{
-	MBU:           MBU(time.Minute),
-	CommitMessage: " [{{.}}]",
-	AutoPush:      true,
+	MBU:              MBU(time.Minute),
+	CommitMessage:    " [{{.}}]",
+	AutoPush:         true,
+	EstimateWarnings: []int{80, 100},
+	Issues: &IssuesConfig{
+		Pattern: `\b[A-Z][A-Z0-9]+-[0-9]+\b|#[0-9]+\b`,
+		Split:   "even",
+	},
+	Submodules: "independent",
 }
 
 type Config struct {
-	MBU           MBU    `json:"mbu"`
-	CommitMessage string `json:"commit_message"`
-	AutoPush      bool   `json:"auto_push"`
+	MBU           MBU                      `json:"mbu"`
+	CommitMessage string                   `json:"commit_message"`
+	AutoPush      bool                     `json:"auto_push"`
+	Export        map[string]*ExportConfig `json:"export"`
+	Billing       *Billing                 `json:"billing"`
+
+	//percentages of an estimate that trigger a warning
+	EstimateWarnings []int `json:"estimate_warnings"`
+
+	Issues *IssuesConfig `json:"issues"`
+
+	//submodules have a timer of their own ('independent')
+	//or count as part of the superproject ('rollup')
+	Submodules string `json:"submodules"`
+
+	//the key of a commit message trailer that records the
+	//time, e.g: 'Time-Spent', no trailer is added when empty
+	Trailer string `json:"trailer"`
+
+	//when activity counts, any time when not configured
+	WorkingHours *WorkingHours `json:"working_hours"`
+
+	//the maximum continuous and daily time of a timer
+	Limits *Limits `json:"limits"`
+
+	//commands run by the background service on timer events,
+	//only read from the system wide and user configuration
+	Hooks *Hooks `json:"hooks"`
 }
 
-func ReadConfig(dir, sysdir string) (*Config, error) {
+// Layer is a single source of configuration: the defaults, the system
+// wide file, the file of the project or that of a sub-project. Each
+// key that a layer sets overrides the value of earlier layers
+type Layer struct {
+	Name string
+	Path string
+	data map[string]interface{}
+}
 
-	//get system wide config
-	sysconf := &Config{}
-	sysconfp := filepath.Join(sysdir, confFilename)
-	sysconfdata, err := ioutil.ReadFile(sysconfp)
-	if err != nil && !os.IsNotExist(err) {
-		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to read system configuration file '%s' even though it exist: {{err}}", sysconfp), err)
-	} else if os.IsNotExist(err) {
-		sysconf = DefaultConfig
-	} else {
-		err := json.Unmarshal(sysconfdata, &sysconf)
-		if err != nil {
-			return nil, errwrap.Wrapf(fmt.Sprintf("Failed to parse system configuration '%s': {{err}}", sysconfp), err)
+func (l *Layer) String() string {
+	if l.Path == "" {
+		return l.Name
+	}
+
+	return fmt.Sprintf("%s (%s)", l.Name, l.Path)
+}
+
+// the defaults as a layer, keys without a default are left out
+func defaultLayer() (*Layer, error) {
+	data, err := json.Marshal(DefaultConfig)
+	if err != nil {
+		return nil, errwrap.Wrapf("Failed to serialize default configuration: {{err}}", err)
+	}
+
+	l := &Layer{Name: "default"}
+	err = decodeLayer(data, &l.data)
+	if err != nil {
+		return nil, errwrap.Wrapf("Failed to deserialize default configuration: {{err}}", err)
+	}
+
+	for k, v := range l.data {
+		if v == nil {
+			delete(l.data, k)
+		}
+	}
+
+	return l, nil
+}
+
+// reads and validates a configuration file, it returns
+// nil when the file doesn't exist
+func readLayer(name, path string) (*Layer, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to read %s configuration file '%s' even though it exist: {{err}}", name, path), err)
+	}
+
+	err = Validate(data)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Invalid %s configuration '%s': {{err}}", name, path), err)
+	}
+
+	l := &Layer{Name: name, Path: path}
+	err = decodeLayer(data, &l.data)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to parse %s configuration '%s': {{err}}", name, path), err)
+	}
+
+	return l, nil
+}
+
+// numbers are kept as written such that they survive a round trip
+func decodeLayer(data []byte, v *map[string]interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err := dec.Decode(v)
+	if err != nil {
+		return err
+	}
+
+	if *v == nil {
+		*v = map[string]interface{}{}
+	}
+
+	return nil
+}
+
+// Layers returns the layers of configuration that apply to the (sub-)project
+// in dir for the current user, in order: the defaults, the system wide file,
+// the file of the user, the file in the root, the files of every sub-project
+// from the root down to dir and finally the environment variables
+func Layers(root, dir, sysdir string) ([]*Layer, error) {
+	userdir, _ := UserPath()
+	return layers(root, dir, sysdir, userdir, true)
+}
+
+// the files that can configure the (sub-)project in dir, in order
+// of precedence, whether they exist or not. Layers are without data
+func fileLayers(root, dir, sysdir, userdir string) []*Layer {
+	files := []*Layer{{Name: "system", Path: Path(sysdir)}}
+	if userdir != "" {
+		files = append(files, &Layer{Name: "user", Path: Path(userdir)})
+	}
+
+	files = append(files, &Layer{Name: "project", Path: Path(root)})
+	rel, err := filepath.Rel(root, dir)
+	if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		p := root
+		for _, part := range strings.Split(rel, string(filepath.Separator)) {
+			p = filepath.Join(p, part)
+			files = append(files, &Layer{Name: "sub-project", Path: Path(p)})
 		}
+	}
+
+	return files
+}
+
+// Files returns the paths of all configuration files that can apply
+// to the (sub-)project in dir for the owner of the user directory,
+// whether they exist or not, e.g: to notice changes to any of them
+func Files(root, dir, sysdir, userdir string) []string {
+	paths := []string{}
+	for _, f := range fileLayers(root, dir, sysdir, userdir) {
+		paths = append(paths, f.Path)
+	}
 
-		err = mergo.Merge(sysconf, DefaultConfig)
+	return paths
+}
+
+func layers(root, dir, sysdir, userdir string, env bool) ([]*Layer, error) {
+	def, err := defaultLayer()
+	if err != nil {
+		return nil, err
+	}
+
+	layers := []*Layer{def}
+	for _, f := range fileLayers(root, dir, sysdir, userdir) {
+		l, err := readLayer(f.Name, f.Path)
 		if err != nil {
-			return nil, errwrap.Wrapf("Failed to merge system config with default config: {{err}} ", err)
+			return nil, err
+		}
+
+		if l == nil {
+			continue
+		}
+
+		//files of (sub-)projects can't set privileged options and neither
+		//can the file of a timer's owner unless the service trusts it
+		if f.Name != "system" && (f.Name != "user" || (!env && !trusted(f.Path))) {
+			for _, k := range privilegedKeys {
+				delete(l.data, k)
+			}
+		}
+
+		layers = append(layers, l)
+	}
+
+	if !env {
+		return layers, nil
+	}
+
+	envs, err := envLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(layers, envs...), nil
+}
+
+// Settings hold the effective value of every key after
+// merging all layers, and the layer each value came from
+type Settings struct {
+	values  map[string]interface{}
+	sources map[string]*Layer
+}
+
+// Keys returns the keys of all effective values in alphabetical order,
+// keys of nested objects are joined with a dot, e.g: 'issues.pattern'
+func (s *Settings) Keys() []string {
+	keys := []string{}
+	for k := range s.sources {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// Get returns the effective value of a key, which can also
+// be an object that holds several keys (e.g: 'issues')
+func (s *Settings) Get(key string) (interface{}, bool) {
+	var v interface{} = s.values
+	for _, part := range strings.Split(key, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		v, ok = m[part]
+		if !ok {
+			return nil, false
 		}
 	}
 
-	//get project wide config
+	return v, true
+}
+
+// Source returns the layer that provided the value of a key
+func (s *Settings) Source(key string) *Layer {
+	return s.sources[key]
+}
+
+// merges src into dst key by key, objects are merged recursively while
+// any other value (including arrays, false and "") replaces what was
+// there. The layer of each value that ends up in dst is recorded
+func mergeValues(dst, src map[string]interface{}, prefix string, l *Layer, sources map[string]*Layer) {
+	for k, v := range src {
+		key := prefix + k
+		for sk := range sources {
+			if sk == key || (!isObject(v) && strings.HasPrefix(sk, key+".")) {
+				delete(sources, sk)
+			}
+		}
+
+		obj, ok := v.(map[string]interface{})
+		if !ok || len(obj) == 0 {
+			if ok {
+				v = map[string]interface{}{}
+			}
+
+			dst[k] = v
+			sources[key] = l
+			continue
+		}
+
+		sub, ok := dst[k].(map[string]interface{})
+		if !ok {
+			sub = map[string]interface{}{}
+			dst[k] = sub
+		}
+
+		mergeValues(sub, obj, key+".", l, sources)
+	}
+}
+
+func isObject(v interface{}) bool {
+	obj, ok := v.(map[string]interface{})
+	return ok && len(obj) > 0
+}
+
+// Merge combines the layers into the effective configuration
+func Merge(layers []*Layer) (*Config, *Settings, error) {
+	s := &Settings{values: map[string]interface{}{}, sources: map[string]*Layer{}}
+	for _, l := range layers {
+		mergeValues(s.values, l.data, "", l, s.sources)
+	}
+
+	data, err := json.Marshal(s.values)
+	if err != nil {
+		return nil, nil, errwrap.Wrapf("Failed to serialize merged configuration: {{err}}", err)
+	}
+
 	conf := &Config{}
-	confp := filepath.Join(dir, confFilename)
-	confdata, err := ioutil.ReadFile(confp)
-	if err != nil && !os.IsNotExist(err) {
-		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to read project configuration  file '%s' even though it exist: {{err}}", confp), err)
-	} else if os.IsNotExist(err) {
-		conf = sysconf
-	} else {
-		err := json.Unmarshal(confdata, &conf)
+	err = json.Unmarshal(data, conf)
+	if err != nil {
+		return nil, nil, errwrap.Wrapf("Failed to parse merged configuration: {{err}}", err)
+	}
+
+	return conf, s, nil
+}
+
+// ReadSettings reads the configuration of the (sub-)project in dir
+// and returns the effective settings along with where each came from
+func ReadSettings(root, dir, sysdir string) (*Config, *Settings, error) {
+	layers, err := Layers(root, dir, sysdir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return Merge(layers)
+}
+
+func ReadConfig(dir, sysdir string) (*Config, error) {
+	return ReadProjectConfig(dir, dir, sysdir)
+}
+
+// ReadProjectConfig reads the configuration of a (sub-)project in the
+// given dir: the configuration of the root is read as usual and the
+// files of every sub-project from the root down to dir are layered
+// on top of it, nearest file wins
+func ReadProjectConfig(root, dir, sysdir string) (*Config, error) {
+	conf, _, err := ReadSettings(root, dir, sysdir)
+	return conf, err
+}
+
+// ReadOwnerConfig reads the configuration of a (sub-)project like
+// ReadProjectConfig does but for the user that owns the given user
+// directory (e.g by the daemon, that runs as another user), the
+// environment variables of the current process are not used. Privileged
+// options of the owner's file are only used when the current user owns it
+func ReadOwnerConfig(root, dir, sysdir, userdir string) (*Config, error) {
+	layers, err := layers(root, dir, sysdir, userdir, false)
+	if err != nil {
+		return nil, err
+	}
+
+	conf, _, err := Merge(layers)
+	return conf, err
+}
+
+// FindProject returns the (sub-)project the given directory belongs
+// to: the nearest directory up to (but excluding) the root that holds
+// a configuration file, or the root itself if there is none
+func FindProject(root, dir string) string {
+	for d := dir; d != filepath.Dir(d); d = filepath.Dir(d) {
+		rel, err := filepath.Rel(root, d)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			break
+		}
+
+		if _, err := os.Stat(filepath.Join(d, confFilename)); err == nil {
+			return d
+		}
+	}
+
+	return root
+}
+
+// FindProjects lists the sub-projects nested in the given directory,
+// that is each directory below it that holds a configuration file.
+// Hidden directories, dependencies and nested repositories (e.g
+// submodules) are not searched
+func FindProjects(dir string) ([]string, error) {
+	projects := []string{}
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
 		if err != nil {
-			return nil, errwrap.Wrapf(fmt.Sprintf("Failed to parse project configuration '%s': {{err}}", confp), err)
+			return err
+		}
+
+		if !fi.IsDir() || p == dir {
+			return nil
+		}
+
+		if strings.HasPrefix(fi.Name(), ".") || fi.Name() == "node_modules" {
+			return filepath.SkipDir
+		}
+
+		for _, nested := range []string{".git", ".hg"} {
+			if _, err := os.Stat(filepath.Join(p, nested)); err == nil {
+				return filepath.SkipDir
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(p, confFilename)); err == nil {
+			projects = append(projects, p)
 		}
 
-		err = mergo.Merge(conf, sysconf)
+		return nil
+	})
+
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to search '%s' for sub-projects: {{err}}", dir), err)
+	}
+
+	return projects, nil
+}
+
+// ParseValue interprets a value given on the command line: anything
+// that is valid JSON (e.g: false, 15, [80, 100]) is used as such and
+// anything else is taken as a string
+func ParseValue(raw string) interface{} {
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return raw
+	}
+
+	return v
+}
+
+// SetValue sets a key (nested keys are joined with a dot) in the
+// configuration file at path, which is created if it doesn't exist.
+// The file is only written when the result is a valid configuration
+func SetValue(path, key string, value interface{}) error {
+	values := map[string]interface{}{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to read configuration file '%s': {{err}}", path), err)
+	} else if err == nil {
+		err = decodeLayer(data, &values)
 		if err != nil {
-			return nil, errwrap.Wrapf("Failed to merge project config with system config: {{err}} ", err)
+			return errwrap.Wrapf(fmt.Sprintf("Failed to parse configuration '%s', fix it by hand first: {{err}}", path), err)
+		}
+	}
+
+	parts := strings.Split(key, ".")
+	m := values
+	for _, part := range parts[:len(parts)-1] {
+		sub, ok := m[part].(map[string]interface{})
+		if !ok {
+			sub = map[string]interface{}{}
+			m[part] = sub
 		}
+
+		m = sub
+	}
+
+	m[parts[len(parts)-1]] = value
+	buff := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(buff)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	err = enc.Encode(values)
+	if err != nil {
+		return errwrap.Wrapf("Failed to serialize configuration: {{err}}", err)
 	}
 
-	return conf, nil
+	//the position of an error is meaningless in the file as it will be
+	err = Validate(buff.Bytes())
+	if verr, ok := err.(*ValidationError); ok {
+		return fmt.Errorf("Refusing to set '%s': %s", key, verr.Message)
+	} else if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Refusing to set '%s': {{err}}", key), err)
+	}
+
+	err = ioutil.WriteFile(path, buff.Bytes(), 0644)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to write configuration file '%s': {{err}}", path), err)
+	}
+
+	return nil
 }
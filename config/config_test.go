@@ -0,0 +1,301 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubProjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "glass_config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "home"))
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+
+	files := map[string]string{
+		"timeglass.json":                           `{"mbu": "5m", "commit_message": " [{{.}}]"}`,
+		"services/api/timeglass.json":              `{"commit_message": " [api {{.}}]"}`,
+		"services/api/v2/timeglass.json":           `{"mbu": "15m"}`,
+		"services/web/src/index.js":                ``,
+		"services/web/node_modules/timeglass.json": `{}`,
+		".cache/timeglass.json":                    `{}`,
+		"vendor/lib/.git":                          ``,
+		"vendor/lib/timeglass.json":                `{}`,
+	}
+
+	for p, content := range files {
+		p = filepath.Join(root, p)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.NoError(t, ioutil.WriteFile(p, []byte(content), 0644))
+	}
+
+	api := filepath.Join(root, "services", "api")
+	v2 := filepath.Join(api, "v2")
+
+	projects, err := FindProjects(root)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{api, v2}, projects)
+
+	projects, err = FindProjects(v2)
+	assert.NoError(t, err)
+	assert.Empty(t, projects)
+
+	assert.Equal(t, root, FindProject(root, root))
+	assert.Equal(t, root, FindProject(root, filepath.Join(root, "services", "web", "src")))
+	assert.Equal(t, api, FindProject(root, api))
+	assert.Equal(t, v2, FindProject(root, filepath.Join(v2, "handlers")))
+	assert.Equal(t, root, FindProject(root, os.TempDir()))
+
+	//settings are inherited from the root and parent projects
+	sysdir := filepath.Join(root, "system")
+	conf, err := ReadProjectConfig(root, api, sysdir)
+	assert.NoError(t, err)
+	assert.Equal(t, MBU(5*time.Minute), conf.MBU)
+	assert.Equal(t, " [api {{.}}]", conf.CommitMessage)
+
+	conf, err = ReadProjectConfig(root, v2, sysdir)
+	assert.NoError(t, err)
+	assert.Equal(t, MBU(15*time.Minute), conf.MBU)
+	assert.Equal(t, " [api {{.}}]", conf.CommitMessage)
+
+	conf, err = ReadProjectConfig(root, root, sysdir)
+	assert.NoError(t, err)
+	assert.Equal(t, " [{{.}}]", conf.CommitMessage)
+}
+
+func TestLayeredSettings(t *testing.T) {
+	root, err := ioutil.TempDir("", "glass_config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "home"))
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+
+	sysdir := filepath.Join(root, "system")
+	assert.NoError(t, os.Mkdir(sysdir, 0755))
+	assert.NoError(t, ioutil.WriteFile(Path(sysdir), []byte(`{"mbu": "5m", "issues": {"split": "first"}}`), 0644))
+	assert.NoError(t, ioutil.WriteFile(Path(root), []byte(`{"auto_push": false, "commit_message": "", "mbu": "10m"}`), 0644))
+
+	//false and empty values override the defaults, objects are merged key by key
+	conf, settings, err := ReadSettings(root, root, sysdir)
+	assert.NoError(t, err)
+	assert.False(t, conf.AutoPush)
+	assert.Equal(t, "", conf.CommitMessage)
+	assert.Equal(t, MBU(10*time.Minute), conf.MBU)
+	assert.Equal(t, "first", conf.Issues.Split)
+	assert.Equal(t, DefaultConfig.Issues.Pattern, conf.Issues.Pattern)
+
+	assert.Equal(t, "default", settings.Source("issues.pattern").String())
+	assert.Equal(t, "system ("+Path(sysdir)+")", settings.Source("issues.split").String())
+	assert.Equal(t, "project ("+Path(root)+")", settings.Source("mbu").String())
+	assert.Contains(t, settings.Keys(), "estimate_warnings")
+
+	v, ok := settings.Get("issues")
+	assert.True(t, ok)
+	assert.Len(t, v, 2)
+
+	//setting values keeps the file valid
+	assert.NoError(t, SetValue(Path(root), "issues.split", ParseValue("even")))
+	assert.NoError(t, SetValue(Path(root), "estimate_warnings", ParseValue("[50, 90]")))
+	assert.Error(t, SetValue(Path(root), "issues.spilt", ParseValue("even")))
+	assert.Error(t, SetValue(Path(root), "auto_push", ParseValue("yes")))
+
+	conf, err = ReadConfig(root, sysdir)
+	assert.NoError(t, err)
+	assert.Equal(t, "even", conf.Issues.Split)
+	assert.Equal(t, []int{50, 90}, conf.EstimateWarnings)
+	assert.False(t, conf.AutoPush)
+}
+
+func TestValidate(t *testing.T) {
+	for data, expected := range map[string]string{
+		`{"mbu": "1m", "issues": {"pattern": "#[0-9]+"}}`:             "",
+		`{"export": {"csv": {"columns": ["date"]}}, "billing": null}`: "",
+		"{\n\t\"mbu\": \"1m\",\n\t\"auto_pus\": true\n}":              "line 3, column 2: unknown key 'auto_pus'",
+		"{\n\t\"issues\": {\"patern\": \"x\"}\n}":                     "line 2, column 13: unknown key 'issues.patern'",
+		"{\n\t\"export\": {\"csv\": {\"colums\": []}}\n}":             "line 2, column 21: unknown key 'export.csv.colums'",
+		"{\n\t\"mbu\": 15\n}":                                         "line 2, column 9: invalid value for 'mbu'",
+		"{\n\t\"mbu\": \"0s\"\n}":                                     "line 2, column 9: invalid value for 'mbu': expected a positive duration",
+		"{\n\t\"mbu\": \"-1m\"\n}":                                    "line 2, column 9: invalid value for 'mbu': expected a positive duration",
+		"{\n\t\"auto_push\": \"no\"\n}":                               "line 2, column 15: invalid value for 'auto_push': expected true or false",
+		"{\n\t\"issues\": true\n}":                                    "line 2, column 12: expected an object for 'issues'",
+		"{\n\t\"limits\": {\"session\": \"4h\", \"daily\": 10}\n}":    "line 2, column 39: invalid value for 'limits.daily'",
+		"{\n\t\"mbu\": \"1m\"\n\t\"auto_push\": true\n}":              "line 3, column 2: invalid character",
+		"{\"mbu\": \"1m\"} {}":                                        "line 1, column 15: unexpected data",
+		"[]":                                                          "line 1, column 1: expected the configuration to be an object",
+		`{"hooks": {"webhooks": [{"url": "https://x.y", "secret": "s"}, {"url": "https://x.y", "secrett": "s"}]}}`: "unknown key 'hooks.webhooks[1].secrett'",
+		`{"hooks": {"commands": [{"comand": "true"}]}}`:                                                            "unknown key 'hooks.commands[0].comand'",
+		`{"hooks": {"commands": ["true"]}}`:                                                                        "expected an object for 'hooks.commands[0]'",
+		`{"hooks": {"commands": [{"events": ["timer.paused"], "command": "true"}], "webhooks": null}}`:             "",
+	} {
+		err := Validate([]byte(data))
+		if expected == "" {
+			assert.NoError(t, err, data)
+		} else if assert.Error(t, err, data) {
+			assert.Contains(t, err.Error(), expected, data)
+		}
+	}
+}
+
+func TestUserAndEnvironment(t *testing.T) {
+	root, err := ioutil.TempDir("", "glass_config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "home"))
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+
+	userdir, err := UserPath()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "home", "timeglass"), userdir)
+	assert.NoError(t, os.MkdirAll(userdir, 0755))
+	assert.NoError(t, ioutil.WriteFile(Path(userdir), []byte(`{"commit_message": " ({{.}})", "mbu": "2m"}`), 0644))
+	assert.NoError(t, ioutil.WriteFile(Path(root), []byte(`{"mbu": "10m"}`), 0644))
+
+	sysdir := filepath.Join(root, "system")
+	conf, settings, err := ReadSettings(root, root, sysdir)
+	assert.NoError(t, err)
+	assert.Equal(t, " ({{.}})", conf.CommitMessage)
+	assert.Equal(t, MBU(10*time.Minute), conf.MBU)
+	assert.Equal(t, "user ("+Path(userdir)+")", settings.Source("commit_message").String())
+
+	//variables override every file, others with the prefix are ignored
+	assert.Equal(t, "TIMEGLASS_ISSUES__SPLIT", EnvName("issues.split"))
+	for k, v := range map[string]string{
+		"TIMEGLASS_AUTO_PUSH":      "false",
+		"TIMEGLASS_COMMIT_MESSAGE": "123",
+		"TIMEGLASS_ISSUES__SPLIT":  "first",
+		"TIMEGLASS_MBU":            "5m",
+		"TIMEGLASS_PUSH":           "1",
+	} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	conf, settings, err = ReadSettings(root, root, sysdir)
+	assert.NoError(t, err)
+	assert.False(t, conf.AutoPush)
+	assert.Equal(t, "123", conf.CommitMessage)
+	assert.Equal(t, "first", conf.Issues.Split)
+	assert.Equal(t, MBU(5*time.Minute), conf.MBU)
+	assert.Equal(t, "environment (TIMEGLASS_MBU)", settings.Source("mbu").String())
+
+	//the owner's configuration is read without the environment
+	conf, err = ReadOwnerConfig(root, root, sysdir, userdir)
+	assert.NoError(t, err)
+	assert.Equal(t, " ({{.}})", conf.CommitMessage)
+	assert.Equal(t, MBU(10*time.Minute), conf.MBU)
+
+	os.Setenv("TIMEGLASS_AUTO_PUSH", "sometimes")
+	_, err = ReadConfig(root, sysdir)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "TIMEGLASS_AUTO_PUSH: expected true or false")
+	}
+}
+
+func TestWorkingHours(t *testing.T) {
+	data := []byte(`{"working_hours": {
+		"timezone": "Europe/Amsterdam",
+		"days": {"mon": ["09:00-12:00", "13:00-17:30"], "sat": ["10:00-24:00"]},
+		"blocked": ["2015-12-28"]
+	}}`)
+
+	assert.NoError(t, Validate(data))
+	conf := &Config{}
+	assert.NoError(t, json.Unmarshal(data, conf))
+
+	wh := conf.WorkingHours
+	assert.False(t, wh.CountsOffHours())
+	ams := wh.Location()
+	for at, expected := range map[time.Time]bool{
+		time.Date(2015, 12, 21, 9, 0, 0, 0, ams):       true,
+		time.Date(2015, 12, 21, 12, 30, 0, 0, ams):     false,
+		time.Date(2015, 12, 21, 17, 29, 59, 0, ams):    true,
+		time.Date(2015, 12, 21, 17, 30, 0, 0, ams):     false,
+		time.Date(2015, 12, 21, 8, 30, 0, 0, time.UTC): true,
+		time.Date(2015, 12, 22, 10, 0, 0, 0, ams):      false,
+		time.Date(2015, 12, 26, 23, 59, 0, 0, ams):     true,
+		time.Date(2015, 12, 28, 10, 0, 0, 0, ams):      false,
+	} {
+		assert.Equal(t, expected, wh.Allows(at), at.String())
+	}
+
+	//without days only the blocked dates are off
+	wh.Days = nil
+	assert.True(t, wh.Allows(time.Date(2015, 12, 22, 3, 0, 0, 0, ams)))
+	assert.False(t, wh.Allows(time.Date(2015, 12, 28, 3, 0, 0, 0, ams)))
+	assert.True(t, (*WorkingHours)(nil).Allows(time.Now()))
+
+	for data, expected := range map[string]string{
+		`{"working_hours": {"days": {"mon": ["9:00-17:00"]}, "policy": "off_hours"}}`: "",
+		`{"working_hours": {"days": {"mon": ["17:00-09:00"]}}}`:                       "end before they start",
+		`{"working_hours": {"days": {"mon": ["09:00"]}}}`:                             "expected hours such as",
+		`{"working_hours": {"days": {"monday": []}}}`:                                 "unknown key 'working_hours.days.monday'",
+		`{"working_hours": {"blocked": ["25-12-2015"]}}`:                              "expected a date such as",
+		`{"working_hours": {"timezone": "Mars/Olympus"}}`:                             "unknown time zone",
+		`{"working_hours": {"policy": "ignore"}}`:                                     "expected 'pause' or 'off_hours'",
+	} {
+		err := Validate([]byte(data))
+		if expected == "" {
+			assert.NoError(t, err, data)
+		} else if assert.Error(t, err, data) {
+			assert.Contains(t, err.Error(), expected, data)
+		}
+	}
+}
+
+func TestPrivilegedOptions(t *testing.T) {
+	root, err := ioutil.TempDir("", "glass_config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "home"))
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+	os.Setenv("TIMEGLASS_HOOKS__TIMEOUT", "1h")
+	defer os.Unsetenv("TIMEGLASS_HOOKS__TIMEOUT")
+
+	//a project can't make the service run commands
+	assert.NoError(t, ioutil.WriteFile(Path(root), []byte(`{"hooks": {"commands": [{"command": "rm -rf /"}]}}`), 0644))
+	conf, err := ReadConfig(root, filepath.Join(root, "system"))
+	assert.NoError(t, err)
+	assert.Nil(t, conf.Hooks)
+
+	userdir, err := UserPath()
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(userdir, 0755))
+	assert.NoError(t, ioutil.WriteFile(Path(userdir), []byte(`{"hooks": {"timeout": "5s", "commands": [{"events": ["timer.paused"], "command": "notify-send paused"}]}}`), 0644))
+	conf, err = ReadConfig(root, filepath.Join(root, "system"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, conf.Hooks) && assert.Len(t, conf.Hooks.Commands, 1) {
+		assert.Equal(t, Duration(5*time.Second), conf.Hooks.Timeout)
+		assert.True(t, conf.Hooks.Commands[0].RunsOn("timer.paused"))
+		assert.False(t, conf.Hooks.Commands[0].RunsOn("timer.reset"))
+	}
+
+	//the service only trusts an owner's file that it owns itself
+	conf, err = ReadOwnerConfig(root, root, filepath.Join(root, "system"), userdir)
+	assert.NoError(t, err)
+	assert.NotNil(t, conf.Hooks)
+
+	assert.NoError(t, os.Chmod(Path(userdir), 0666))
+	conf, err = ReadOwnerConfig(root, root, filepath.Join(root, "system"), userdir)
+	assert.NoError(t, err)
+	assert.Nil(t, conf.Hooks)
+
+	assert.NoError(t, os.Chmod(Path(userdir), 0644))
+	assert.NoError(t, os.Chmod(userdir, 0777))
+	conf, err = ReadOwnerConfig(root, root, filepath.Join(root, "system"), userdir)
+	assert.NoError(t, err)
+	assert.Nil(t, conf.Hooks)
+
+	assert.True(t, Privileged("hooks.timeout"))
+	assert.False(t, Privileged("hooksmith"))
+	assert.Error(t, Validate([]byte(`{"hooks": {"commands": [{"events": ["timer.stopped"]}]}}`)))
+	assert.Error(t, Validate([]byte(`{"hooks": {"webhooks": [{"url": "dashboard.example.com"}]}}`)))
+	assert.NoError(t, Validate([]byte(`{"hooks": {"webhooks": [{"url": "https://dashboard.example.com", "batch_interval": "1s"}]}}`)))
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// options can be overridden by environment variables that start
+// with this prefix followed by the key in upper case, nested keys
+// are joined with a double underscore, e.g: TIMEGLASS_ISSUES__SPLIT
+var EnvPrefix = "TIMEGLASS_"
+
+// UserPath returns the directory that holds the configuration of the
+// current user: $XDG_CONFIG_HOME/timeglass when it is set or else the
+// platform's equivalent, e.g: ~/.config/timeglass on Linux
+func UserPath() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "timeglass"), nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		return filepath.Join(dir, "Timeglass"), nil
+	}
+
+	return filepath.Join(dir, "timeglass"), nil
+}
+
+// EnvName returns the environment variable that overrides a key
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(key, ".", "__", -1))
+}
+
+// the keys of all options that can be set by a single value, options
+// that hold an object with arbitrary keys (e.g: 'export') are left out
+func leafKeys(t reflect.Type, prefix string) map[string]reflect.Type {
+	keys := map[string]reflect.Type{}
+	for name, ft := range fieldsOf(t) {
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		switch ft.Kind() {
+		case reflect.Struct:
+			for k, kt := range leafKeys(ft, prefix+name+".") {
+				keys[k] = kt
+			}
+		case reflect.Map:
+		default:
+			keys[prefix+name] = ft
+		}
+	}
+
+	return keys
+}
+
+// a layer for each environment variable that overrides an option, other
+// variables with the prefix (e.g: those used by the hooks) are ignored
+func envLayers() ([]*Layer, error) {
+	known := leafKeys(reflect.TypeOf(Config{}), "")
+	keys := []string{}
+	for k := range known {
+		if !Privileged(k) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	layers := []*Layer{}
+	for _, key := range keys {
+		name := EnvName(key)
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		//values are taken as JSON when possible (e.g: false) but
+		//options that expect a string don't need to be quoted
+		value := ParseValue(raw)
+		data, _ := json.Marshal(value)
+		if json.Unmarshal(data, reflect.New(known[key]).Interface()) != nil {
+			value = raw
+			data, _ = json.Marshal(value)
+			err := json.Unmarshal(data, reflect.New(known[key]).Interface())
+			if _, ok := err.(*json.UnmarshalTypeError); ok {
+				err = fmt.Errorf("expected %s", describe(known[key]))
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid value '%s' for environment variable %s: %s", raw, name, err)
+			}
+		}
+
+		l := &Layer{Name: "environment", Path: name, data: map[string]interface{}{}}
+		parts := strings.Split(key, ".")
+		m := l.data
+		for _, part := range parts[:len(parts)-1] {
+			sub := map[string]interface{}{}
+			m[part] = sub
+			m = sub
+		}
+
+		m[parts[len(parts)-1]] = value
+		layers = append(layers, l)
+	}
+
+	return layers, nil
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// the events of a timer that hooks can run on
+var HookEvents = []string{
+	"timer.started",
+	"timer.paused",
+	"timer.unpaused",
+	"timer.timeout",
+	"timer.reset",
+	"timer.failed",
+	"estimate.threshold",
+	"limit.reached",
+}
+
+// options that make the background service run commands, anyone
+// that can change a project could otherwise run anything with the
+// privileges of the service. These are only read from the system
+// wide and user files, other layers can't set them
+var privilegedKeys = []string{"hooks"}
+
+// Privileged returns whether a key can only be set in
+// the system wide or user configuration
+func Privileged(key string) bool {
+	for _, k := range privilegedKeys {
+		if key == k || strings.HasPrefix(key, k+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Hooks configure the commands that the background service runs on
+// the events of timers (e.g: to show a notification) and the
+// endpoints it posts them to
+type Hooks struct {
+	Timeout     Duration       `json:"timeout"`
+	Concurrency int            `json:"concurrency"`
+	Commands    []*HookCommand `json:"commands"`
+	Webhooks    []*Webhook     `json:"webhooks"`
+}
+
+// HookCommand is run on the given events, or on
+// every event when there are none
+type HookCommand struct {
+	Events  []HookEvent `json:"events"`
+	Command string      `json:"command"`
+}
+
+// RunsOn returns whether the command runs on the given event
+func (h *HookCommand) RunsOn(event string) bool {
+	return runsOn(h.Events, event)
+}
+
+// Webhook receives the events of timers as JSON posts, these are signed
+// with the secret when there is one. Events are sent in batches: the
+// first event is posted after the interval, along with any that came in
+// since, at most a batch size of events at a time
+type Webhook struct {
+	URL           WebhookURL  `json:"url"`
+	Secret        string      `json:"secret"`
+	Events        []HookEvent `json:"events"`
+	BatchSize     int         `json:"batch_size"`
+	BatchInterval Duration    `json:"batch_interval"`
+}
+
+// RunsOn returns whether the event is posted to the webhook
+func (w *Webhook) RunsOn(event string) bool {
+	return runsOn(w.Events, event)
+}
+
+func runsOn(events []HookEvent, event string) bool {
+	if len(events) == 0 {
+		return true
+	}
+
+	for _, e := range events {
+		if string(e) == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WebhookURL is the http(s) address of a webhook
+type WebhookURL string
+
+func (u *WebhookURL) UnmarshalJSON(data []byte) error {
+	raw, err := strconv.Unquote(string(data))
+	if err != nil {
+		return fmt.Errorf("expected an url such as \"https://example.com/timeglass\" but got %s", data)
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("expected an http(s) url such as \"https://example.com/timeglass\" but got %s", data)
+	}
+
+	*u = WebhookURL(raw)
+	return nil
+}
+
+// HookEvent is the type of event a hook runs on, e.g: "timer.paused"
+type HookEvent string
+
+func (e *HookEvent) UnmarshalJSON(data []byte) error {
+	raw, err := strconv.Unquote(string(data))
+	if err != nil {
+		return fmt.Errorf("expected an event such as \"timer.paused\" but got %s", data)
+	}
+
+	for _, known := range HookEvents {
+		if raw == known {
+			*e = HookEvent(raw)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown event \"%s\", expected one of: %s", raw, strings.Join(HookEvents, ", "))
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// policies for activity outside of working hours: the timer either
+// stays paused or counts the time separately as off-hours
+var (
+	PolicyPause    = "pause"
+	PolicyOffHours = "off_hours"
+)
+
+// WorkingHours determines when activity counts as work, the
+// timer doesn't measure time outside of these hours
+type WorkingHours struct {
+	Timezone Timezone   `json:"timezone"`
+	Days     *WeekHours `json:"days"`
+	Blocked  []Date     `json:"blocked"`
+	Policy   Policy     `json:"policy"`
+}
+
+// WeekHours holds the working hours of each weekday, days
+// without any hours are not worked on
+type WeekHours struct {
+	Mon []Hours `json:"mon"`
+	Tue []Hours `json:"tue"`
+	Wed []Hours `json:"wed"`
+	Thu []Hours `json:"thu"`
+	Fri []Hours `json:"fri"`
+	Sat []Hours `json:"sat"`
+	Sun []Hours `json:"sun"`
+}
+
+// Of returns the working hours of a weekday
+func (w *WeekHours) Of(day time.Weekday) []Hours {
+	return [][]Hours{w.Sun, w.Mon, w.Tue, w.Wed, w.Thu, w.Fri, w.Sat}[day]
+}
+
+// Location returns the time zone the working hours are in
+func (w *WorkingHours) Location() *time.Location {
+	if w == nil || w.Timezone == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(string(w.Timezone))
+	if err != nil {
+		return time.Local
+	}
+
+	return loc
+}
+
+// CountsOffHours returns whether time outside of working hours is
+// counted as off-hours instead of keeping the timer paused
+func (w *WorkingHours) CountsOffHours() bool {
+	return w != nil && string(w.Policy) == PolicyOffHours
+}
+
+// Allows returns whether t falls within the working hours: it isn't on a
+// blocked date and, when hours are configured per day, within one of the
+// hours of its weekday. Any time is allowed when nothing is configured
+func (w *WorkingHours) Allows(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+
+	t = t.In(w.Location())
+	date := t.Format(dateLayout)
+	for _, d := range w.Blocked {
+		if string(d) == date {
+			return false
+		}
+	}
+
+	if w.Days == nil {
+		return true
+	}
+
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	for _, h := range w.Days.Of(t.Weekday()) {
+		from, to, err := h.Bounds()
+		if err == nil && offset >= from && offset < to {
+			return true
+		}
+	}
+
+	return false
+}
+
+var dateLayout = "2006-01-02"
+
+// Date is a calendar day written as "2015-12-25"
+type Date string
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	raw, err := strconv.Unquote(string(data))
+	if err != nil {
+		return fmt.Errorf("expected a date such as \"2015-12-25\" but got %s", data)
+	}
+
+	_, err = time.Parse(dateLayout, raw)
+	if err != nil {
+		return fmt.Errorf("expected a date such as \"2015-12-25\" but got %s", data)
+	}
+
+	*d = Date(raw)
+	return nil
+}
+
+// Hours is a range of time on a day written as "09:00-17:30",
+// the end is exclusive and can be "24:00" for the end of the day
+type Hours string
+
+// Bounds returns the start and end of the hours as offsets from midnight
+func (h Hours) Bounds() (time.Duration, time.Duration, error) {
+	parts := strings.Split(string(h), "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected hours such as \"09:00-17:30\" but got \"%s\"", h)
+	}
+
+	from, err := parseClock(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	to, err := parseClock(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if to <= from {
+		return 0, 0, fmt.Errorf("hours \"%s\" end before they start, split hours that cross midnight over two days", h)
+	}
+
+	return from, to, nil
+}
+
+func (h *Hours) UnmarshalJSON(data []byte) error {
+	raw, err := strconv.Unquote(string(data))
+	if err != nil {
+		return fmt.Errorf("expected hours such as \"09:00-17:30\" but got %s", data)
+	}
+
+	_, _, err = Hours(raw).Bounds()
+	if err != nil {
+		return err
+	}
+
+	*h = Hours(raw)
+	return nil
+}
+
+// parses a time of day such as "09:30" into an offset from midnight
+func parseClock(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 || len(parts[1]) != 2 {
+		return 0, fmt.Errorf("expected a time of day such as \"09:30\" but got \"%s\"", s)
+	}
+
+	h, err := strconv.Atoi(parts[0])
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("expected a time of day such as \"09:30\" but got \"%s\"", s)
+	}
+
+	m, err := strconv.Atoi(parts[1])
+	if err != nil || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("expected a time of day such as \"09:30\" but got \"%s\"", s)
+	}
+
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// Timezone is the name of a time zone, e.g: "Europe/Amsterdam"
+type Timezone string
+
+func (tz *Timezone) UnmarshalJSON(data []byte) error {
+	raw, err := strconv.Unquote(string(data))
+	if err != nil {
+		return fmt.Errorf("expected a time zone such as \"Europe/Amsterdam\" but got %s", data)
+	}
+
+	_, err = time.LoadLocation(raw)
+	if err != nil {
+		return fmt.Errorf("unknown time zone \"%s\"", raw)
+	}
+
+	*tz = Timezone(raw)
+	return nil
+}
+
+// Policy is what happens to activity outside of working hours
+type Policy string
+
+func (p *Policy) UnmarshalJSON(data []byte) error {
+	raw, err := strconv.Unquote(string(data))
+	if err != nil || (raw != "" && raw != PolicyPause && raw != PolicyOffHours) {
+		return fmt.Errorf("expected '%s' or '%s' but got %s", PolicyPause, PolicyOffHours, data)
+	}
+
+	*p = Policy(raw)
+	return nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// trusted returns whether the user's configuration file can be trusted
+// with privileged options by the current process: the service reads the
+// file of a timer's owner but might run with more privileges (e.g: as
+// root), so the file and its directory need to be owned by the user of
+// the process and only be writable by it. Platforms without file owners
+// (e.g: Windows) never trust it, use the system wide file there
+func trusted(path string) bool {
+	for _, p := range []string{path, filepath.Dir(path)} {
+		fi, err := os.Stat(p)
+		if err != nil || fi.Mode().Perm()&0022 != 0 {
+			return false
+		}
+
+		uid, ok := ownerOf(fi)
+		if !ok || uid != os.Getuid() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// the uid of the file's owner, read without build specific
+// code from the platform's stat result when it has one
+func ownerOf(fi os.FileInfo) (int, bool) {
+	v := reflect.Indirect(reflect.ValueOf(fi.Sys()))
+	if v.Kind() != reflect.Struct {
+		return 0, false
+	}
+
+	f := v.FieldByName("Uid")
+	if !f.IsValid() || f.Kind() != reflect.Uint32 {
+		return 0, false
+	}
+
+	return int(f.Uint()), true
+}
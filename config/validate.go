@@ -0,0 +1,254 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Validate checks a configuration file strictly: it must be a single
+// JSON object that only holds known keys with values of the right type.
+// Errors mention the line and column of the offending part of the file
+func Validate(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	v := &validator{data: data, dec: dec}
+	tok, err := dec.Token()
+	if err != nil {
+		return v.syntaxErr(err)
+	}
+
+	if tok != json.Delim('{') {
+		return v.errorf(0, true, "expected the configuration to be an object")
+	}
+
+	err = v.object(reflect.TypeOf(Config{}), "")
+	if err != nil {
+		return err
+	}
+
+	offset := dec.InputOffset()
+	_, err = dec.Token()
+	if err != io.EOF {
+		return v.errorf(offset, true, "unexpected data after the configuration object")
+	}
+
+	return nil
+}
+
+// ValidationError describes what is wrong with a configuration
+// file and where, lines and columns start at 1
+type ValidationError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+type validator struct {
+	data []byte
+	dec  *json.Decoder
+}
+
+// errorf returns an error at an offset in the data, when 'skip' is set any
+// whitespace and separators at the offset are skipped such that it
+// points to the next key or value
+func (v *validator) errorf(offset int64, skip bool, format string, args ...interface{}) error {
+	for skip && offset < int64(len(v.data)) && strings.ContainsRune(" \t\r\n,:", rune(v.data[offset])) {
+		offset++
+	}
+
+	e := &ValidationError{Line: 1, Column: 1, Message: fmt.Sprintf(format, args...)}
+	for _, b := range v.data[:offset] {
+		if b == '\n' {
+			e.Line++
+			e.Column = 1
+		} else {
+			e.Column++
+		}
+	}
+
+	return e
+}
+
+func (v *validator) syntaxErr(err error) error {
+	//the offset of a syntax error is just after the invalid character
+	if serr, ok := err.(*json.SyntaxError); ok {
+		offset := serr.Offset
+		if offset > 0 {
+			offset--
+		}
+
+		return v.errorf(offset, false, "%s", serr)
+	}
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return v.errorf(int64(len(v.data)), false, "unexpected end of the configuration")
+	}
+
+	return err
+}
+
+// the json name of each field of a struct type
+func fieldsOf(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		fields[name] = f.Type
+	}
+
+	return fields
+}
+
+// checks the members of an object, the opening brace is already read
+func (v *validator) object(t reflect.Type, path string) error {
+	var fields map[string]reflect.Type
+	if t.Kind() == reflect.Struct {
+		fields = fieldsOf(t)
+	}
+
+	for v.dec.More() {
+		offset := v.dec.InputOffset()
+		tok, err := v.dec.Token()
+		if err != nil {
+			return v.syntaxErr(err)
+		}
+
+		key, _ := tok.(string)
+		ft, ok := fields[key]
+		if fields == nil {
+			ft = t.Elem()
+		} else if !ok {
+			return v.errorf(offset, true, "unknown key '%s'", path+key)
+		}
+
+		err = v.value(ft, path+key)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := v.dec.Token()
+	if err != nil {
+		return v.syntaxErr(err)
+	}
+
+	return nil
+}
+
+// checks a single value, objects are checked key by key while any
+// other value must be deserializable into the type it configures
+func (v *validator) value(t reflect.Type, key string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	offset := v.dec.InputOffset()
+	elem := t
+	if t.Kind() == reflect.Slice {
+		elem = t.Elem()
+		for elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+	}
+
+	//lists of objects are walked element by element
+	if t.Kind() == reflect.Slice && (elem.Kind() == reflect.Struct || elem.Kind() == reflect.Map) && v.peek() == '[' {
+		_, err := v.dec.Token()
+		if err != nil {
+			return v.syntaxErr(err)
+		}
+
+		for i := 0; v.dec.More(); i++ {
+			err = v.value(elem, fmt.Sprintf("%s[%d]", key, i))
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = v.dec.Token()
+		if err != nil {
+			return v.syntaxErr(err)
+		}
+
+		return nil
+	}
+
+	if t.Kind() == reflect.Struct || t.Kind() == reflect.Map {
+		//peek at the value, objects are walked and anything else is checked below
+		var raw json.RawMessage
+		if v.peek() == '{' {
+			_, err := v.dec.Token()
+			if err != nil {
+				return v.syntaxErr(err)
+			}
+
+			return v.object(t, key+".")
+		}
+
+		err := v.dec.Decode(&raw)
+		if err != nil {
+			return v.syntaxErr(err)
+		}
+
+		if string(raw) != "null" {
+			return v.errorf(offset, true, "expected an object for '%s'", key)
+		}
+
+		return nil
+	}
+
+	var raw json.RawMessage
+	err := v.dec.Decode(&raw)
+	if err != nil {
+		return v.syntaxErr(err)
+	}
+
+	err = json.Unmarshal(raw, reflect.New(t).Interface())
+	if err != nil {
+		if terr, ok := err.(*json.UnmarshalTypeError); ok {
+			err = fmt.Errorf("expected %s but got %s", describe(t), terr.Value)
+		}
+
+		return v.errorf(offset, true, "invalid value for '%s': %s", key, err)
+	}
+
+	return nil
+}
+
+// the first character of the next value, the separators
+// that precede it are skipped (e.g: of an element in a list)
+func (v *validator) peek() byte {
+	trimmed := bytes.TrimLeft(v.data[v.dec.InputOffset():], " \t\r\n:,")
+	if len(trimmed) == 0 {
+		return 0
+	}
+
+	return trimmed[0]
+}
+
+// describes the kind of value a type expects to a human
+func describe(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "a list of " + strings.TrimPrefix(describe(t.Elem()), "a ") + "s"
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "a number"
+	}
+
+	return t.String()
+}
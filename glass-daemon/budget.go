@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Budget is an estimate that applies to the work that is
+// measured by a timer, time spent is what was recorded
+// before, the time of the timer itself is added to it
+type Budget struct {
+	Key        string        `json:"key"`
+	Name       string        `json:"name"`
+	Estimate   time.Duration `json:"estimate"`
+	Spent      time.Duration `json:"spent"`
+	Thresholds []int         `json:"thresholds"`
+	Warned     int           `json:"warned"`
+}
+
+// the percentage of the estimate that is spent
+func (b *Budget) Percentage(live time.Duration) int {
+	if b.Estimate <= 0 {
+		return 0
+	}
+
+	return int(100 * (b.Spent + live) / b.Estimate)
+}
+
+// the highest threshold that is crossed, or 0 if none
+func (b *Budget) Crossed(live time.Duration) int {
+	crossed := 0
+	pct := b.Percentage(live)
+	for _, th := range b.Thresholds {
+		if th > 0 && pct >= th && th > crossed {
+			crossed = th
+		}
+	}
+
+	return crossed
+}
+
+// a human readable description of the state of the budget
+func (b *Budget) Describe(live time.Duration) string {
+	return fmt.Sprintf("%s of the %s estimate for %s is spent (%d%%)", b.Spent+live, b.Estimate, b.Name, b.Percentage(live))
+}
+
+// SetBudgets replaces the budgets of the timer, thresholds that
+// were already warned about are kept unless the estimate changed
+func (t *Timer) SetBudgets(budgets []*Budget) {
+	for _, b := range budgets {
+		for _, old := range t.timerData.Budgets {
+			if old.Key == b.Key && old.Estimate == b.Estimate {
+				b.Warned = old.Warned
+			}
+		}
+	}
+
+	t.timerData.Budgets = budgets
+	t.checkBudgets()
+}
+
+func (t *Timer) Budgets() []*Budget {
+	return t.timerData.Budgets
+}
+
+// emits an event for each budget that crossed a
+// threshold it didn't warn about before
+func (t *Timer) checkBudgets() {
+	for _, b := range t.timerData.Budgets {
+		crossed := b.Crossed(t.Time())
+		if crossed <= b.Warned {
+			continue
+		}
+
+		b.Warned = crossed
+		t.Emit(&Event{
+			Type:    EventEstimateThreshold,
+			Message: fmt.Sprintf("%s, crossed the %d%% threshold", b.Describe(t.Time()), crossed),
+			Data: map[string]interface{}{
+				"key":        b.Key,
+				"estimate":   b.Estimate.String(),
+				"spent":      (b.Spent + t.Time()).String(),
+				"percentage": b.Percentage(t.Time()),
+				"threshold":  crossed,
+			},
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudgetCrossed(t *testing.T) {
+	b := &Budget{Estimate: time.Hour, Spent: 40 * time.Minute, Thresholds: []int{80, 100}}
+	assert.Equal(t, 66, b.Percentage(0))
+	assert.Equal(t, 0, b.Crossed(0))
+	assert.Equal(t, 80, b.Crossed(10*time.Minute))
+	assert.Equal(t, 100, b.Crossed(time.Hour))
+
+	b.Estimate = 0
+	assert.Equal(t, 0, b.Crossed(time.Hour))
+}
+
+func TestBudgetEvents(t *testing.T) {
+	dir := setupTestProject(t)
+
+	timer, err := NewTimer(dir)
+	assert.NoError(t, err)
+
+	//crossing the first threshold emits once
+	timer.SetBudgets([]*Budget{{Key: "branch:x", Name: "branch 'x'", Estimate: time.Hour, Spent: 50 * time.Minute, Thresholds: []int{80, 100}}})
+	assert.Len(t, timer.Events(), 1)
+	assert.Equal(t, EventEstimateThreshold, timer.Events()[0].Type)
+	assert.Equal(t, 80, timer.Events()[0].Data["threshold"])
+
+	timer.SetBudgets([]*Budget{{Key: "branch:x", Name: "branch 'x'", Estimate: time.Hour, Spent: 55 * time.Minute, Thresholds: []int{80, 100}}})
+	assert.Len(t, timer.Events(), 1)
+
+	//live time crosses the next
+	timer.timerData.Time = 10 * time.Minute
+	timer.checkBudgets()
+	assert.Len(t, timer.Events(), 2)
+	assert.Equal(t, 100, timer.Events()[1].Data["threshold"])
+
+	//a new estimate warns again
+	timer.SetBudgets([]*Budget{{Key: "branch:x", Name: "branch 'x'", Estimate: 2 * time.Hour, Spent: 55 * time.Minute, Thresholds: []int{80, 100}}})
+	assert.Len(t, timer.Events(), 2)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// the number of recent events that each timer keeps
+var MaxTimerEvents = 20
+
+const (
+	EventEstimateThreshold = "estimate.threshold"
+	EventLimitReached      = "limit.reached"
+
+	EventTimerStarted  = "timer.started"
+	EventTimerPaused   = "timer.paused"
+	EventTimerUnpaused = "timer.unpaused"
+	EventTimerTimeout  = "timer.timeout"
+	EventTimerReset    = "timer.reset"
+	EventTimerFailed   = "timer.failed"
+)
+
+// Event describes something noteworthy that happened
+// to a timer, e.g: an estimate that was exceeded
+type Event struct {
+	Type    string                 `json:"type"`
+	Dir     string                 `json:"dir"`
+	Time    time.Time              `json:"time"`
+	Message string                 `json:"message"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// Emit records an event for the timer and runs its hooks, only
+// the most recent events are kept with the timer's state
+func (t *Timer) Emit(ev *Event) {
+	ev.Dir = t.Dir()
+	ev.Time = time.Now()
+	log.Printf("Timer for project '%s' emitted '%s': %s", t.Dir(), ev.Type, ev.Message)
+
+	t.timerData.Events = append(t.timerData.Events, ev)
+	if len(t.timerData.Events) > MaxTimerEvents {
+		t.timerData.Events = t.timerData.Events[len(t.timerData.Events)-MaxTimerEvents:]
+	}
+
+	t.dispatch(ev)
+}
+
+// notify runs the hooks of a moment in the life of the timer (e.g: a
+// pause), unlike emitted events these aren't kept with the timer's state
+func (t *Timer) notify(typ, message string) {
+	t.dispatch(&Event{
+		Type:    typ,
+		Dir:     t.Dir(),
+		Time:    time.Now(),
+		Message: message,
+		Data: map[string]interface{}{
+			"measured": t.Time().String(),
+		},
+	})
+}
+
+// runs the hook commands of an event and queues it for the webhooks
+func (t *Timer) dispatch(ev *Event) {
+	t.confmu.RLock()
+	hooks, webhooks := t.hooks, t.webhooks
+	t.confmu.RUnlock()
+
+	hooks.Run(ev)
+	t.sink.Add(webhooks, ev)
+}
+
+// Events returns the most recent events, oldest first
+func (t *Timer) Events() []*Event {
+	return t.timerData.Events
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Heartbeat tells that someone works on a file of the project without
+// writing to it, e.g: an editor in which code is read or debugged
+type Heartbeat struct {
+	File   string    `json:"file"`
+	Source string    `json:"source"`
+	Time   time.Time `json:"time"`
+
+	//closed once the timer handled the heartbeat
+	done chan struct{}
+}
+
+// Heartbeat counts as activity in the directory of the file: it
+// wakes up a paused timer and postpones the timeout exactly like
+// a written file does. The source (e.g: 'vim') is recorded
+func (t *Timer) Heartbeat(file, source string) error {
+	if !t.running {
+		return fmt.Errorf("Timer for '%s' is not running", t.Dir())
+	}
+
+	if !within(t.Dir(), file) {
+		return fmt.Errorf("File '%s' is not part of project '%s'", file, t.Dir())
+	}
+
+	hb := &Heartbeat{File: file, Source: source, Time: time.Now(), done: make(chan struct{})}
+	select {
+	case t.beats <- hb:
+	case <-time.After(time.Second):
+		return fmt.Errorf("Timer for '%s' didn't receive the heartbeat in time", t.Dir())
+	}
+
+	//wait such that callers see the timer as it is after the heartbeat
+	select {
+	case <-hb.done:
+	case <-time.After(time.Second):
+		return fmt.Errorf("Timer for '%s' didn't handle the heartbeat in time", t.Dir())
+	}
+
+	return nil
+}
+
+// LastHeartbeat returns the most recent heartbeat, if any
+func (t *Timer) LastHeartbeat() *Heartbeat {
+	return t.timerData.Heartbeat
+}
+
+// whether path is dir or inside it
+func within(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
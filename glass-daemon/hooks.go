@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/timeglass/glass/config"
+)
+
+// hooks that don't configure these use the defaults
+var (
+	DefaultHookTimeout     = time.Second * 10
+	DefaultHookConcurrency = 2
+)
+
+// hookRunner runs the configured commands on the events of a timer, only
+// a limited number of commands run at the same time (others wait for
+// their turn) and each command is killed when it doesn't finish in time
+type hookRunner struct {
+	commands []*config.HookCommand
+	timeout  time.Duration
+	slots    chan struct{}
+	wg       sync.WaitGroup
+}
+
+// returns nil when there are no commands to run
+func newHookRunner(conf *config.Hooks) *hookRunner {
+	if conf == nil || len(conf.Commands) == 0 {
+		return nil
+	}
+
+	h := &hookRunner{
+		commands: conf.Commands,
+		timeout:  time.Duration(conf.Timeout),
+		slots:    make(chan struct{}, DefaultHookConcurrency),
+	}
+
+	if h.timeout <= 0 {
+		h.timeout = DefaultHookTimeout
+	}
+
+	if conf.Concurrency > 0 {
+		h.slots = make(chan struct{}, conf.Concurrency)
+	}
+
+	return h
+}
+
+// Run starts every command that runs on the event in the background
+func (h *hookRunner) Run(ev *Event) {
+	if h == nil {
+		return
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("Failed to serialize event '%s' for hooks: %s", ev.Type, err)
+		return
+	}
+
+	env := append(os.Environ(), hookEnv(ev)...)
+	for _, cmd := range h.commands {
+		if !cmd.RunsOn(ev.Type) {
+			continue
+		}
+
+		h.wg.Add(1)
+		go func(command string) {
+			defer h.wg.Done()
+			h.slots <- struct{}{}
+			defer func() { <-h.slots }()
+
+			h.exec(command, ev, data, env)
+		}(cmd.Command)
+	}
+}
+
+// Wait blocks until all commands that were started have finished
+func (h *hookRunner) Wait() {
+	if h == nil {
+		return
+	}
+
+	h.wg.Wait()
+}
+
+func (h *hookRunner) exec(command string, ev *Event, data []byte, env []string) {
+	cmd := hookCommand(command)
+	out := bytes.NewBuffer(nil)
+	cmd.Dir = ev.Dir
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	err := cmd.Start()
+	if err != nil {
+		log.Printf("Hook '%s' for event '%s' of project '%s' failed to start: %s", command, ev.Type, ev.Dir, err)
+		return
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err = <-done:
+	case <-time.After(h.timeout):
+		kerr := killHook(cmd)
+		if kerr != nil {
+			log.Printf("Failed to kill hook '%s' of project '%s': %s", command, ev.Dir, kerr)
+		}
+
+		<-done
+		log.Printf("Hook '%s' for event '%s' of project '%s' was killed after %s", command, ev.Type, ev.Dir, h.timeout)
+		return
+	}
+
+	if err != nil {
+		log.Printf("Hook '%s' for event '%s' of project '%s' failed: %s, output: %s", command, ev.Type, ev.Dir, err, strings.TrimSpace(out.String()))
+	}
+}
+
+// the event as environment variables, the data of the event
+// is added with its key in upper case, e.g: TIMEGLASS_DATA_LIMIT
+func hookEnv(ev *Event) []string {
+	env := []string{
+		"TIMEGLASS_EVENT=" + ev.Type,
+		"TIMEGLASS_DIR=" + ev.Dir,
+		"TIMEGLASS_EVENT_TIME=" + ev.Time.Format(time.RFC3339),
+		"TIMEGLASS_MESSAGE=" + ev.Message,
+	}
+
+	keys := []string{}
+	for k := range ev.Data {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, fmt.Sprintf("TIMEGLASS_DATA_%s=%v", strings.ToUpper(k), ev.Data[k]))
+	}
+
+	return env
+}
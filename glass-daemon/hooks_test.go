@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timeglass/glass/config"
+)
+
+func TestTimerHooks(t *testing.T) {
+	dir := setupTestProject(t)
+	out, err := ioutil.TempDir("", "glass_hooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(out)
+
+	//hooks are read from the configuration of the user
+	userdir := filepath.Join(out, "user")
+	assert.NoError(t, os.Mkdir(userdir, 0755))
+	hooks := fmt.Sprintf(`{"hooks": {"commands": [
+		{"events": ["timer.started", "timer.paused"], "command": "cat > %s/$TIMEGLASS_EVENT.json"}
+	]}}`, out)
+	writeProjectFile(t, userdir, "timeglass.json", hooks)
+
+	timer, err := NewTimer(dir)
+	assert.NoError(t, err)
+	timer.SetUserDir(userdir)
+
+	timer.Start()
+	defer timer.Stop()
+	timer.Pause()
+	timer.Unpause()
+	timer.hooks.Wait()
+
+	ev := &Event{}
+	data, err := ioutil.ReadFile(filepath.Join(out, "timer.paused.json"))
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, ev))
+	assert.Equal(t, EventTimerPaused, ev.Type)
+	assert.Equal(t, dir, ev.Dir)
+	assert.Contains(t, ev.Data, "measured")
+
+	_, err = os.Stat(filepath.Join(out, "timer.started.json"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(out, "timer.unpaused.json"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestHookTimeoutAndConcurrency(t *testing.T) {
+	ev := &Event{Type: EventTimerReset, Dir: os.TempDir(), Time: time.Now(), Data: map[string]interface{}{"measured": "1m0s"}}
+	assert.Contains(t, strings.Join(hookEnv(ev), "\n"), "TIMEGLASS_DATA_MEASURED=1m0s")
+
+	//commands that take too long are killed
+	h := newHookRunner(&config.Hooks{
+		Timeout:  config.Duration(time.Millisecond * 50),
+		Commands: []*config.HookCommand{{Command: "sleep 5"}},
+	})
+
+	start := time.Now()
+	h.Run(ev)
+	h.Wait()
+	assert.True(t, time.Since(start) < time.Second*3)
+
+	//along with what they started in the background
+	h = newHookRunner(&config.Hooks{
+		Timeout:  config.Duration(time.Millisecond * 50),
+		Commands: []*config.HookCommand{{Command: "sleep 5 & sleep 5; echo done"}},
+	})
+
+	start = time.Now()
+	h.Run(ev)
+	h.Wait()
+	assert.True(t, time.Since(start) < time.Second*3)
+
+	//others wait for their turn
+	h = newHookRunner(&config.Hooks{
+		Concurrency: 1,
+		Commands:    []*config.HookCommand{{Command: "sleep 0.1"}, {Command: "sleep 0.1"}},
+	})
+
+	start = time.Now()
+	h.Run(ev)
+	h.Wait()
+	assert.True(t, time.Since(start) >= time.Millisecond*200)
+	assert.Nil(t, newHookRunner(&config.Hooks{}))
+}
@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os/exec"
+)
+
+func hookCommand(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
+
+// only the shell is killed, children that outlive it and keep its
+// output open delay the hook until they finish by themselves
+func killHook(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
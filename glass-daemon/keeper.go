@@ -15,6 +15,7 @@ type Keeper struct {
 	ledgerPath string
 	stop       chan struct{}
 	save       chan struct{}
+	sink       *Sink
 
 	keeperData *keeperData
 }
@@ -32,6 +33,13 @@ func NewKeeper(path string) (*Keeper, error) {
 		},
 	}
 
+	//events for webhooks are queued next to the ledger
+	var err error
+	k.sink, err = NewSink(filepath.Join(path, "webhooks.json"))
+	if err != nil {
+		log.Printf("Failed to load webhook queue, starting with an empty one: %s", err)
+	}
+
 	//attempt to open json file, if it exsts
 	k.ledgerPath = filepath.Join(path, "ledger.json")
 	return k, k.Load()
@@ -50,6 +58,7 @@ func (k *Keeper) Add(t *Timer) error {
 		log.Printf("New timer '%s' for keeper, adding to collection...", t.Dir())
 		k.keeperData.Timers[t.Dir()] = t
 		t.SetSave(k.save)
+		t.SetSink(k.sink)
 	} else {
 		log.Printf("Timer '%s' exists for keeper, unpausing...", t.Dir())
 		tt.Unpause()
@@ -68,6 +77,23 @@ func (k *Keeper) Get(dir string) (*Timer, error) {
 	return nil, fmt.Errorf("No known timer for '%s'", dir)
 }
 
+// Find returns the timer of the project that holds the
+// path, the timer of the innermost (sub-)project wins
+func (k *Keeper) Find(path string) (*Timer, error) {
+	var found *Timer
+	for dir, t := range k.keeperData.Timers {
+		if within(dir, path) && (found == nil || len(dir) > len(found.Dir())) {
+			found = t
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("No known timer for a project that holds '%s'", path)
+	}
+
+	return found, nil
+}
+
 func (k *Keeper) Remove(dir string) error {
 	if t, ok := k.keeperData.Timers[dir]; ok {
 		delete(k.keeperData.Timers, dir)
@@ -82,10 +108,12 @@ func (k *Keeper) Remove(dir string) error {
 
 func (k *Keeper) Stop() {
 	k.stop <- struct{}{}
+	k.sink.Stop()
 }
 
 func (k *Keeper) Start() {
 	log.Printf("Started time keeper on %s", time.Now())
+	go k.sink.Start()
 	defer func() {
 		log.Printf("Stopped time keeper on %s", time.Now())
 	}()
@@ -124,6 +152,7 @@ func (k *Keeper) Load() error {
 		//immediately restart and link save channel if not paused
 		for _, t := range k.keeperData.Timers {
 			t.SetSave(k.save)
+			t.SetSink(k.sink)
 			if !t.IsPaused() {
 				t.Start()
 			}
@@ -85,3 +85,39 @@ func TestAddRemoveTimer(t *testing.T) {
 	assert.NotContains(t, string(data), "latency")
 	assert.True(t, timer.IsPaused())
 }
+
+func TestFindTimer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_keeper")
+	assert.NoError(t, err)
+
+	pdir := filepath.Join(dir, "project_x")
+	sdir := filepath.Join(pdir, "sub")
+	assert.NoError(t, os.MkdirAll(sdir, 0755))
+
+	k, err := NewKeeper(dir)
+	assert.NoError(t, err)
+
+	go k.Start()
+	defer k.Stop()
+
+	project, err := NewTimer(pdir)
+	assert.NoError(t, err)
+	assert.NoError(t, k.Add(project))
+
+	sub, err := NewTimer(sdir)
+	assert.NoError(t, err)
+	assert.NoError(t, k.Add(sub))
+
+	found, err := k.Find(filepath.Join(pdir, "main.go"))
+	assert.NoError(t, err)
+	assert.Equal(t, pdir, found.Dir())
+
+	//the innermost project holds the file
+	found, err = k.Find(filepath.Join(sdir, "main.go"))
+	assert.NoError(t, err)
+	assert.Equal(t, sdir, found.Dir())
+
+	//a sibling directory that shares the prefix isn't held
+	_, err = k.Find(pdir + "_y" + string(filepath.Separator) + "main.go")
+	assert.Error(t, err)
+}
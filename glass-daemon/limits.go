@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// counts a measured unit towards the limits, the time of
+// the day starts over when the (working hours) date changes
+func (t *Timer) countLimits(now time.Time) {
+	day := now.In(t.workingHours().Location()).Format("2006-01-02")
+	if day != t.timerData.Day {
+		t.timerData.Day = day
+		t.timerData.Today = 0
+	}
+
+	t.timerData.Running += t.timerData.MBU
+	t.timerData.Today += t.timerData.MBU
+}
+
+// holds the timer when it ran longer than the maximum session
+// or measured more than the daily maximum, unless the latter
+// was already confirmed for the day
+func (t *Timer) checkLimits() {
+	limits := t.currentLimits()
+	if limits == nil || t.timerData.Held != "" {
+		return
+	}
+
+	if max := time.Duration(limits.Session); max > 0 && t.timerData.Running >= max {
+		t.hold("session", max, fmt.Sprintf("ran for %s without a break, the maximum session is %s", t.timerData.Running, max))
+	} else if max := time.Duration(limits.Daily); max > 0 && t.timerData.Today >= max && t.timerData.Confirmed != t.timerData.Day {
+		t.hold("daily", max, fmt.Sprintf("measured %s today, the daily maximum is %s", t.timerData.Today, max))
+	}
+}
+
+// pauses the timer until the time of the session that
+// reached a limit is either confirmed or discarded
+func (t *Timer) hold(limit string, max time.Duration, reason string) {
+	held := t.timerData.Running
+	if held > t.timerData.Time {
+		held = t.timerData.Time
+	}
+
+	t.timerData.Held = reason
+	t.timerData.HeldTime = held
+	t.Emit(&Event{
+		Type:    EventLimitReached,
+		Message: fmt.Sprintf("Timer %s", reason),
+		Data: map[string]interface{}{
+			"limit": limit,
+			"max":   max.String(),
+			"held":  held.String(),
+		},
+	})
+
+	t.pause(EventTimerPaused, fmt.Sprintf("Timer was held because it %s", reason))
+}
+
+// Held returns why the timer was held after reaching
+// a limit, it is empty when the timer isn't held
+func (t *Timer) Held() string {
+	return t.timerData.Held
+}
+
+// HeldTime returns the time of the session that reached a limit
+func (t *Timer) HeldTime() time.Duration {
+	return t.timerData.HeldTime
+}
+
+// Confirm releases a held timer such that activity wakes it up again.
+// The time of the session that reached the limit is kept or discarded,
+// once kept the daily maximum doesn't hold the timer again that day
+func (t *Timer) Confirm(keep bool) {
+	if t.timerData.Held == "" {
+		return
+	}
+
+	if keep {
+		if limits := t.currentLimits(); limits != nil && limits.Daily > 0 && t.timerData.Today >= time.Duration(limits.Daily) {
+			t.timerData.Confirmed = t.timerData.Day
+		}
+
+		log.Printf("Timer for project '%s' kept the %s that was held", t.Dir(), t.timerData.HeldTime)
+	} else {
+		t.timerData.Time -= t.timerData.HeldTime
+		t.timerData.Today -= t.timerData.HeldTime
+		if t.timerData.Time < 0 {
+			t.timerData.Time = 0
+		}
+
+		if t.timerData.Today < 0 {
+			t.timerData.Today = 0
+		}
+
+		log.Printf("Timer for project '%s' discarded the %s that was held", t.Dir(), t.timerData.HeldTime)
+	}
+
+	t.timerData.Held = ""
+	t.timerData.HeldTime = 0
+	t.timerData.Running = 0
+	t.EmitSave()
+}
@@ -8,6 +8,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"path/filepath"
 	"strings"
 
 	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
@@ -65,11 +66,31 @@ func (s *Server) timersCreate(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			//sub-projects inherit the configuration of the root and
+			//the configuration of the user is that of the timer's owner
+			t.SetRoot(r.Form.Get("root"))
+			t.SetUserDir(r.Form.Get("user_dir"))
+
 			err = s.keeper.Add(t)
 			if err != nil {
 				s.Respond(w, errwrap.Wrapf("Failed to add new timer to keeper: {{err}}", err))
 				return
 			}
+
+			//the timer might already exist, update the one that is kept
+			t, err = s.keeper.Get(dir)
+			if err != nil {
+				s.Respond(w, errwrap.Wrapf("Failed get timer: {{err}}", err))
+				return
+			}
+
+			//the owner of a timer doesn't change once it is known
+			t.SetRoot(r.Form.Get("root"))
+			if t.UserDir() == "" {
+				t.SetUserDir(r.Form.Get("user_dir"))
+			}
+
+			t.SetExcludes(r.Form["exclude"])
 		}
 	}
 
@@ -119,6 +140,12 @@ func (s *Server) timersReset(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			//the time of held timers is confirmed first, unless forced
+			if t.Held() != "" && r.Form.Get("force") != "true" {
+				s.Respond(w, fmt.Errorf("Timer of '%s' is held because it %s, confirm its time before resetting it", dir, t.Held()))
+				return
+			}
+
 			t.Reset()
 		}
 	}
@@ -126,6 +153,116 @@ func (s *Server) timersReset(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// confirms the time of held timers, it is kept
+// unless 'keep' is false in which case it is discarded
+func (s *Server) timersConfirm(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		s.Respond(w, err)
+		return
+	}
+
+	keep := r.Form.Get("keep") != "false"
+	if dirs, ok := r.Form["dir"]; !ok {
+		s.Respond(w, fmt.Errorf("dir parameter is mandatory"))
+		return
+	} else {
+		for _, dir := range dirs {
+			t, err := s.keeper.Get(dir)
+			if err != nil {
+				s.Respond(w, errwrap.Wrapf("Failed get timer: {{err}}", err))
+				return
+			}
+
+			t.Confirm(keep)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) timersBudget(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		s.Respond(w, err)
+		return
+	}
+
+	budgets := []*Budget{}
+	if raw := r.Form.Get("budgets"); raw != "" {
+		err = json.Unmarshal([]byte(raw), &budgets)
+		if err != nil {
+			s.Respond(w, errwrap.Wrapf("Failed to decode budgets: {{err}}", err))
+			return
+		}
+	}
+
+	if dirs, ok := r.Form["dir"]; !ok {
+		s.Respond(w, fmt.Errorf("dir parameter is mandatory"))
+		return
+	} else {
+		for _, dir := range dirs {
+			t, err := s.keeper.Get(dir)
+			if err != nil {
+				s.Respond(w, errwrap.Wrapf("Failed get timer: {{err}}", err))
+				return
+			}
+
+			t.SetBudgets(budgets)
+			t.EmitSave()
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// heartbeats of editors count as activity in the project of a file, the
+// timer is found by the file unless its directory is given. It responds
+// with the timer such that editors can show its state
+func (s *Server) timersHeartbeat(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		s.Respond(w, err)
+		return
+	}
+
+	file := r.Form.Get("file")
+	if file == "" {
+		s.Respond(w, fmt.Errorf("file parameter is mandatory"))
+		return
+	}
+
+	if !filepath.IsAbs(file) {
+		s.Respond(w, fmt.Errorf("file parameter must be an absolute path, got '%s'", file))
+		return
+	}
+
+	source := r.Form.Get("source")
+	if source == "" {
+		source = "unknown"
+	}
+
+	var t *Timer
+	if dir := r.Form.Get("dir"); dir != "" {
+		t, err = s.keeper.Get(dir)
+	} else {
+		t, err = s.keeper.Find(filepath.Clean(file))
+	}
+
+	if err != nil {
+		s.Respond(w, errwrap.Wrapf("Failed to get timer: {{err}}", err))
+		return
+	}
+
+	err = t.Heartbeat(filepath.Clean(file), source)
+	if err != nil {
+		s.Respond(w, errwrap.Wrapf("Failed to handle heartbeat: {{err}}", err))
+		return
+	}
+
+	s.Respond(w, t)
+}
+
 func (s *Server) timersInfo(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
@@ -185,6 +322,9 @@ func NewServer(httpb string, keeper *Keeper) (*Server, error) {
 	mux.HandleFunc("/api/timers.delete", s.timersDelete)
 	mux.HandleFunc("/api/timers.reset", s.timersReset)
 	mux.HandleFunc("/api/timers.info", s.timersInfo)
+	mux.HandleFunc("/api/timers.budget", s.timersBudget)
+	mux.HandleFunc("/api/timers.confirm", s.timersConfirm)
+	mux.HandleFunc("/api/timers.heartbeat", s.timersHeartbeat)
 	return s, nil
 }
 
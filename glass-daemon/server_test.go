@@ -6,7 +6,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -84,3 +86,47 @@ func TestCreateInfoRemoveTimer(t *testing.T) {
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
+
+func TestResetHeldTimer(t *testing.T) {
+	dir := setupTestProject(t)
+	writeProjectFile(t, dir, "timeglass.json", `{"mbu": "5ms", "limits": {"session": "10ms"}}`)
+
+	k, err := NewKeeper(filepath.Dir(dir))
+	assert.NoError(t, err)
+
+	go k.Start()
+	defer k.Stop()
+
+	svr, err := NewServer(":0", k)
+	assert.NoError(t, err)
+
+	timer, err := NewTimer(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, k.Add(timer))
+	<-time.After(time.Millisecond * 15)
+	assert.NotEqual(t, "", timer.Held())
+
+	//held time isn't thrown away, e.g: by the post-commit hook
+	params := &url.Values{"dir": []string{dir}}
+	r, err := http.NewRequest("GET", "/api/timers.reset?"+params.Encode(), nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	svr.timersReset(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "is held because it")
+	assert.NotEqual(t, "", timer.Held())
+	assert.NotEqual(t, time.Duration(0), timer.Time())
+
+	//unless forced
+	params.Set("force", "true")
+	r, err = http.NewRequest("GET", "/api/timers.reset?"+params.Encode(), nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	svr.timersReset(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	<-time.After(time.Millisecond * 2)
+	assert.Equal(t, "", timer.Held())
+	assert.Equal(t, time.Duration(0), timer.Time())
+}
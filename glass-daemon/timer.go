@@ -1,9 +1,14 @@
 package main
 
 import (
+	"crypto/sha1"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
@@ -20,16 +25,65 @@ type timerData struct {
 	Timeout time.Duration `json:"timeout"`
 	MBU     time.Duration `json:"mbu"`
 	Time    time.Duration `json:"time"`
+	Start   time.Time     `json:"session_start"`
+	End     time.Time     `json:"session_end"`
+
+	//the number of times the timer resumed measuring
+	//after a pause, including the first start
+	Sessions int `json:"sessions"`
+
+	//time measured outside of working hours, it is
+	//kept apart from the time and reported separately
+	OffHours time.Duration `json:"off_hours"`
+
+	//time measured since the timer last resumed and on the
+	//current day, these count towards the configured limits
+	Running time.Duration `json:"running"`
+	Today   time.Duration `json:"today"`
+	Day     string        `json:"day"`
+
+	//why the timer was held after reaching a limit, the time of
+	//the session that reached it needs to be confirmed first
+	Held      string        `json:"held"`
+	HeldTime  time.Duration `json:"held_time"`
+	Confirmed string        `json:"confirmed"`
+
+	//the last heartbeat of an editor, see: Heartbeat()
+	Heartbeat *Heartbeat `json:"heartbeat"`
+
+	Budgets []*Budget `json:"budgets"`
+	Events  []*Event  `json:"events"`
+
+	//activity in these sub directories doesn't count, e.g:
+	//submodules and worktrees that have timers of their own
+	Excludes []string `json:"excludes"`
+
+	//the root of the repository when the timer is
+	//for a sub-project, its configuration is inherited
+	Root string `json:"root"`
+
+	//the configuration directory of the user that started the
+	//timer, the daemon itself usually runs as another user
+	UserDir string `json:"user_dir"`
 }
 
 type Timer struct {
-	running   bool
-	timerData *timerData
-	monitor   monitor.M
-	save      chan struct{}
-	stopto    chan struct{}
-	stoptick  chan struct{}
-	reset     chan struct{}
+	running    bool
+	confStamp  string
+	confFailed bool
+	confmu     sync.RWMutex //guards the configuration below, it is reloaded on changes
+	hours      *config.WorkingHours
+	limits     *config.Limits
+	hooks      *hookRunner
+	webhooks   []*config.Webhook
+	sink       *Sink
+	timerData  *timerData
+	monitor    monitor.M
+	save       chan struct{}
+	stopto     chan struct{}
+	stoptick   chan struct{}
+	reset      chan struct{}
+	beats      chan *Heartbeat
 }
 
 func NewTimer(dir string) (*Timer, error) {
@@ -59,26 +113,22 @@ func (t *Timer) Start() {
 	t.timerData.Failed = ""
 
 	//load project/ system specific configuration
-	sysdir, err := SystemTimeglassPathCreateIfNotExist()
-	if err != nil {
-		err = errwrap.Wrapf(fmt.Sprintf("Failed to read system config: {{err}}"), err)
-		t.timerData.Failed = err.Error()
-	}
-
-	conf, err := config.ReadConfig(t.Dir(), sysdir)
+	t.confStamp = t.configStamp()
+	conf, err := t.readConfig()
 	if err != nil {
 		err = errwrap.Wrapf(fmt.Sprintf("Failed to read configuration for '%s': {{err}}, using default", t.Dir()), err)
-		t.timerData.Failed = err.Error()
+		t.fail(err.Error())
+		t.confFailed = true
 		conf = config.DefaultConfig
 	}
 
-	t.timerData.MBU = time.Duration(conf.MBU)
-	t.timerData.Timeout = 4 * t.timerData.MBU
+	t.applyConfig(conf)
 
 	//lazily initiate control members
 	t.stopto = make(chan struct{})
 	t.stoptick = make(chan struct{})
 	t.reset = make(chan struct{})
+	t.beats = make(chan *Heartbeat)
 
 	//setup monitor, if not done yet
 	wakeup := make(chan monitor.DirEvent)
@@ -87,14 +137,12 @@ func (t *Timer) Start() {
 		t.monitor, err = monitor.New(t.Dir(), monitor.Recursive, t.timerData.Latency)
 		if err != nil {
 			err = errwrap.Wrapf(fmt.Sprintf("Failed to create monitor for directory '%s': {{err}}", t.Dir()), err)
-			t.timerData.Failed = err.Error()
-			log.Print(err)
+			t.fail(err.Error())
 		} else {
 			wakeup, err = t.monitor.Start()
 			if err != nil {
 				err = errwrap.Wrapf("Failed to start monitor: {{err}}", err)
-				t.timerData.Failed = err.Error()
-				log.Print(err)
+				t.fail(err.Error())
 			}
 
 			merrs = t.monitor.Errors()
@@ -105,9 +153,12 @@ func (t *Timer) Start() {
 	}
 
 	//handle stops, pauses, timeouts and wakeups
+	//held timers stay paused until their time is confirmed
 	log.Printf("Timer for project '%s' was started (and unpaused) explicitely", t.Dir())
-	t.timerData.Paused = false
+	t.timerData.Paused = t.timerData.Held != ""
+	t.timerData.Running = 0
 	t.running = true
+	t.notify(EventTimerStarted, "Timer was started")
 	go func() {
 		for {
 
@@ -118,19 +169,19 @@ func (t *Timer) Start() {
 				return
 			case merr := <-merrs:
 				log.Printf("Monitor Error: %s", merr)
-				t.timerData.Failed = merr.Error()
+				t.fail(merr.Error())
+				t.confFailed = false
 			case <-time.After(t.timerData.Timeout):
 				if !t.IsPaused() {
 					log.Printf("Timer for project '%s' timed out after %s", t.Dir(), t.timerData.Timeout)
 				}
-				t.Pause()
+				t.pause(EventTimerTimeout, fmt.Sprintf("Timer timed out after %s without activity", t.timerData.Timeout))
 			case ev := <-wakeup:
-				if t.IsPaused() {
-					log.Printf("Timer for project '%s' woke up after some activity in '%s'", t.Dir(), ev.Dir())
-					t.Unpause()
-				} else {
-					log.Printf("Timer saw activity for project '%s' in '%s' but is already unpaused", t.Dir(), ev.Dir())
-				}
+				t.activity(ev.Dir(), "some activity")
+			case hb := <-t.beats:
+				t.timerData.Heartbeat = hb
+				t.activity(filepath.Dir(hb.File), fmt.Sprintf("a heartbeat from '%s'", hb.Source))
+				close(hb.done)
 			}
 		}
 	}()
@@ -139,7 +190,25 @@ func (t *Timer) Start() {
 	go func() {
 		for {
 			if !t.timerData.Paused {
-				t.timerData.Time += t.timerData.MBU
+				now := time.Now()
+				hours := t.workingHours()
+				if hours.Allows(now) {
+					if t.timerData.Time == 0 {
+						t.timerData.Start = now
+						t.timerData.Sessions = 1
+					}
+
+					t.timerData.Time += t.timerData.MBU
+					t.timerData.End = now
+					t.countLimits(now)
+					t.checkBudgets()
+					t.checkLimits()
+				} else if hours.CountsOffHours() {
+					t.timerData.OffHours += t.timerData.MBU
+				} else {
+					log.Printf("Timer for project '%s' is outside of working hours", t.Dir())
+					t.pause(EventTimerPaused, "Timer was paused outside of working hours")
+				}
 			}
 
 			t.EmitSave()
@@ -148,29 +217,164 @@ func (t *Timer) Start() {
 				return
 			case <-t.reset:
 				t.timerData.Time = 0
+				t.timerData.Start = time.Time{}
+				t.timerData.End = time.Time{}
+				t.timerData.Sessions = 0
+				t.timerData.OffHours = 0
+				t.timerData.Held = ""
+				t.timerData.HeldTime = 0
 				log.Printf("Timer for project '%s' was reset", t.Dir())
+				t.notify(EventTimerReset, "Timer was reset")
 			case <-time.After(t.timerData.MBU):
 			}
 		}
 	}()
 }
 
+// handles activity in a directory of the project (e.g: a file was written),
+// it wakes up a paused timer unless the directory is excluded, the timer
+// is held or it is outside of working hours
+func (t *Timer) activity(dir, cause string) {
+	t.reloadConfig()
+	if t.isExcluded(dir) {
+		return
+	}
+
+	if !t.IsPaused() {
+		log.Printf("Timer saw %s for project '%s' in '%s' but is already unpaused", cause, t.Dir(), dir)
+		return
+	}
+
+	if t.timerData.Held != "" {
+		log.Printf("Timer for project '%s' saw %s in '%s' but is held until its time is confirmed", t.Dir(), cause, dir)
+		return
+	}
+
+	if hours := t.workingHours(); !hours.Allows(time.Now()) && !hours.CountsOffHours() {
+		log.Printf("Timer for project '%s' saw %s in '%s' outside of working hours, it stays paused", t.Dir(), cause, dir)
+		return
+	}
+
+	log.Printf("Timer for project '%s' woke up after %s in '%s'", t.Dir(), cause, dir)
+	t.Unpause()
+}
+
+func (t *Timer) readConfig() (*config.Config, error) {
+	sysdir, err := SystemTimeglassPathCreateIfNotExist()
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to read system config: {{err}}"), err)
+	}
+
+	return config.ReadOwnerConfig(t.Root(), t.Dir(), sysdir, t.timerData.UserDir)
+}
+
+func (t *Timer) applyConfig(conf *config.Config) {
+	t.timerData.MBU = time.Duration(conf.MBU)
+	t.timerData.Timeout = 4 * t.timerData.MBU
+
+	t.confmu.Lock()
+	defer t.confmu.Unlock()
+	t.hours = conf.WorkingHours
+	t.limits = conf.Limits
+	t.hooks = newHookRunner(conf.Hooks)
+	t.webhooks = nil
+	if conf.Hooks != nil {
+		t.webhooks = conf.Hooks.Webhooks
+	}
+}
+
+func (t *Timer) workingHours() *config.WorkingHours {
+	t.confmu.RLock()
+	defer t.confmu.RUnlock()
+	return t.hours
+}
+
+func (t *Timer) currentLimits() *config.Limits {
+	t.confmu.RLock()
+	defer t.confmu.RUnlock()
+	return t.limits
+}
+
+// marks the timer as failed and runs the hooks for it
+func (t *Timer) fail(reason string) {
+	t.timerData.Failed = reason
+	log.Print(reason)
+	t.notify(EventTimerFailed, reason)
+}
+
+// a fingerprint of the content of all configuration
+// files that apply to the timer, existing or not
+func (t *Timer) configStamp() string {
+	sysdir, _ := SystemTimeglassPath()
+	h := sha1.New()
+	for _, p := range config.Files(t.Root(), t.Dir(), sysdir, t.timerData.UserDir) {
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			data = []byte("missing")
+		}
+
+		fmt.Fprintf(h, "%s\x00%x\x00", p, sha1.Sum(data))
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// reloadConfig is called on file activity, when any of the configuration
+// files changed they are read again and the mbu, timeout, working hours and
+// limits are applied to the running timer without touching the measured
+// time. An invalid configuration fails the timer, the last valid one stays
+// in effect
+func (t *Timer) reloadConfig() {
+	stamp := t.configStamp()
+	if stamp == t.confStamp {
+		return
+	}
+
+	t.confStamp = stamp
+	conf, err := t.readConfig()
+	if err != nil {
+		t.fail(fmt.Sprintf("Changed configuration for '%s' is invalid, still using an mbu of %s until it is fixed: %s", t.Dir(), t.timerData.MBU, err))
+		t.confFailed = true
+		return
+	}
+
+	if t.confFailed {
+		t.timerData.Failed = ""
+		t.confFailed = false
+	}
+
+	t.applyConfig(conf)
+	log.Printf("Timer for project '%s' reloaded its configuration, the mbu is now %s", t.Dir(), t.timerData.MBU)
+}
+
 func (t *Timer) Pause() {
+	t.pause(EventTimerPaused, "Timer was paused")
+}
+
+// pauses the timer and runs the hooks of the given event
+func (t *Timer) pause(event, message string) {
 	if !t.running || t.IsPaused() {
 		return
 	}
 
 	t.timerData.Paused = true
 	log.Printf("Timer for project '%s' was paused", t.Dir())
+	t.notify(event, message)
 }
 
 func (t *Timer) Unpause() {
-	if !t.running || !t.IsPaused() {
+	if !t.running || !t.IsPaused() || t.timerData.Held != "" {
 		return
 	}
 
 	t.timerData.Paused = false
+	t.timerData.Running = 0
+	if t.timerData.Time > 0 && t.workingHours().Allows(time.Now()) {
+		t.timerData.Sessions++
+	}
+
 	log.Printf("Timer for project '%s' was unpaused", t.Dir())
+	t.notify(EventTimerUnpaused, "Timer was unpaused")
 }
 
 func (t *Timer) Reset() {
@@ -178,6 +382,13 @@ func (t *Timer) Reset() {
 		//if running state is not correct
 		//this migth cause race conditions
 		t.timerData.Time = 0
+		t.timerData.Start = time.Time{}
+		t.timerData.End = time.Time{}
+		t.timerData.Sessions = 0
+		t.timerData.OffHours = 0
+		t.timerData.Held = ""
+		t.timerData.HeldTime = 0
+		t.notify(EventTimerReset, "Timer was reset")
 		return
 	}
 
@@ -223,6 +434,11 @@ func (t *Timer) SetSave(ch chan struct{}) {
 	t.save = ch
 }
 
+// SetSink sets where the events of the timer are queued for webhooks
+func (t *Timer) SetSink(s *Sink) {
+	t.sink = s
+}
+
 func (t *Timer) HasFailed() string {
 	return t.timerData.Failed
 }
@@ -235,6 +451,75 @@ func (t *Timer) Time() time.Duration {
 	return t.timerData.Time
 }
 
+// SessionStart returns when the timer first measured
+// time since it was last reset
+func (t *Timer) SessionStart() time.Time {
+	return t.timerData.Start
+}
+
+// Sessions returns the number of uninterrupted periods of
+// activity that make up the measured time
+func (t *Timer) Sessions() int {
+	return t.timerData.Sessions
+}
+
+// OffHours returns the time measured outside of working
+// hours, it is not part of the time
+func (t *Timer) OffHours() time.Duration {
+	return t.timerData.OffHours
+}
+
+// SessionEnd returns when the timer last measured time
+func (t *Timer) SessionEnd() time.Time {
+	return t.timerData.End
+}
+
+func (t *Timer) SetExcludes(dirs []string) {
+	t.timerData.Excludes = dirs
+	t.EmitSave()
+}
+
+func (t *Timer) Excludes() []string {
+	return t.timerData.Excludes
+}
+
+// whether the directory is (inside) one of the excluded directories
+func (t *Timer) isExcluded(dir string) bool {
+	for _, ex := range t.timerData.Excludes {
+		if dir == ex || strings.HasPrefix(dir, ex+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UserDir returns the configuration directory of the timer's owner
+func (t *Timer) UserDir() string {
+	return t.timerData.UserDir
+}
+
+func (t *Timer) SetUserDir(dir string) {
+	t.timerData.UserDir = dir
+	t.EmitSave()
+}
+
+func (t *Timer) SetRoot(root string) {
+	t.timerData.Root = root
+	t.EmitSave()
+}
+
+// Root returns the root of the repository the timer's
+// project belongs to, which is the directory itself
+// unless the timer is for a sub-project
+func (t *Timer) Root() string {
+	if t.timerData.Root == "" {
+		return t.timerData.Dir
+	}
+
+	return t.timerData.Root
+}
+
 func (t *Timer) Dir() string {
 	return t.timerData.Dir
 }
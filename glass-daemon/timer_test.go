@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -228,3 +229,191 @@ func TestUnpause(t *testing.T) {
 	<-time.After(time.Millisecond * 5)
 	assertTime(t, timer, time.Millisecond*15)
 }
+
+func TestExcludedActivity(t *testing.T) {
+	dir := setupTestProject(t)
+	sub := filepath.Join(dir, "submodule")
+	assert.NoError(t, os.Mkdir(sub, 0755))
+	<-time.After(time.Millisecond * 20)
+
+	timer, err := NewTimer(dir)
+	assert.NoError(t, err)
+	timer.SetExcludes([]string{sub})
+
+	timer.Start()
+	defer timer.Stop()
+	timer.Pause()
+
+	writeProjectFile(t, sub, "file.go", "package main")
+	<-time.After(time.Millisecond * 200)
+	assert.True(t, timer.IsPaused())
+
+	//the timer times out again quickly, check while it's awake
+	writeProjectFile(t, dir, "file.go", "package main")
+	woke := false
+	for i := 0; i < 200 && !woke; i++ {
+		<-time.After(time.Millisecond)
+		woke = !timer.IsPaused()
+	}
+
+	assert.True(t, woke)
+}
+
+func TestHeartbeat(t *testing.T) {
+	dir := setupTestProject(t)
+	timer, err := NewTimer(dir)
+	assert.NoError(t, err)
+
+	//heartbeats need a running timer
+	assert.Error(t, timer.Heartbeat(filepath.Join(dir, "file.go"), "vim"))
+
+	timer.Start()
+	defer timer.Stop()
+	timer.Pause()
+
+	//files outside of the project are refused
+	assert.Error(t, timer.Heartbeat(filepath.Join(filepath.Dir(dir), "file.go"), "vim"))
+	assert.True(t, timer.IsPaused())
+	assert.Nil(t, timer.LastHeartbeat())
+
+	//the heartbeat is handled before it returns
+	assert.NoError(t, timer.Heartbeat(filepath.Join(dir, "file.go"), "vim"))
+	assert.False(t, timer.IsPaused())
+	if assert.NotNil(t, timer.LastHeartbeat()) {
+		assert.Equal(t, "vim", timer.LastHeartbeat().Source)
+		assert.Equal(t, filepath.Join(dir, "file.go"), timer.LastHeartbeat().File)
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	dir := setupTestProject(t)
+
+	timer, err := NewTimer(dir)
+	assert.NoError(t, err)
+
+	timer.Start()
+	defer timer.Stop()
+
+	<-time.After(time.Millisecond * 20)
+	measured := timer.Time()
+	assert.NotEqual(t, time.Duration(0), measured)
+
+	waitFor := func(cond func() bool) bool {
+		for i := 0; i < 200; i++ {
+			if cond() {
+				return true
+			}
+
+			<-time.After(time.Millisecond)
+		}
+
+		return false
+	}
+
+	//a changed mbu applies to the running timer, time is kept
+	writeProjectFile(t, dir, "timeglass.json", `{"mbu": "7ms"}`)
+	assert.True(t, waitFor(func() bool { return timer.timerData.MBU == time.Millisecond*7 }))
+	assert.Equal(t, time.Millisecond*28, timer.timerData.Timeout)
+	assert.True(t, timer.Time() >= measured)
+	assert.Equal(t, "", timer.HasFailed())
+
+	//an invalid file fails the timer but keeps the last mbu
+	writeProjectFile(t, dir, "timeglass.json", `{"mbu": "7ms", "mbuu": "1h"}`)
+	assert.True(t, waitFor(func() bool { return timer.HasFailed() != "" }))
+	assert.Contains(t, timer.HasFailed(), "unknown key 'mbuu'")
+	assert.Equal(t, time.Millisecond*7, timer.timerData.MBU)
+	assert.True(t, timer.running)
+
+	writeProjectFile(t, dir, "timeglass.json", `{"mbu": "6ms"}`)
+	assert.True(t, waitFor(func() bool { return timer.HasFailed() == "" }))
+	assert.Equal(t, time.Millisecond*6, timer.timerData.MBU)
+}
+
+func TestSessions(t *testing.T) {
+	dir := setupTestProject(t)
+	timer, err := NewTimer(dir)
+	assert.NoError(t, err)
+
+	timer.Start()
+	defer timer.Stop()
+	<-time.After(time.Millisecond * 10)
+	assert.Equal(t, 1, timer.Sessions())
+
+	timer.Pause()
+	timer.Unpause()
+	timer.Pause()
+	timer.Unpause()
+	assert.Equal(t, 3, timer.Sessions())
+
+	timer.Reset()
+	<-time.After(time.Millisecond * 10)
+	assert.Equal(t, 1, timer.Sessions())
+}
+
+func TestOutsideWorkingHours(t *testing.T) {
+	dir := setupTestProject(t)
+	today := time.Now()
+	blocked := fmt.Sprintf(`"blocked": ["%s", "%s"]`, today.Format("2006-01-02"), today.Add(24*time.Hour).Format("2006-01-02"))
+	writeProjectFile(t, dir, "timeglass.json", `{"mbu": "5ms", "working_hours": {`+blocked+`}}`)
+
+	timer, err := NewTimer(dir)
+	assert.NoError(t, err)
+
+	timer.Start()
+	<-time.After(time.Millisecond * 20)
+	assert.True(t, timer.IsPaused())
+	assert.Equal(t, time.Duration(0), timer.Time())
+
+	//activity doesn't wake it up
+	writeProjectFile(t, dir, "file.go", "package main")
+	<-time.After(time.Millisecond * 20)
+	assert.True(t, timer.IsPaused())
+	assert.Equal(t, time.Duration(0), timer.Time())
+	timer.Stop()
+
+	//or counts separately
+	writeProjectFile(t, dir, "timeglass.json", `{"mbu": "5ms", "working_hours": {"policy": "off_hours", `+blocked+`}}`)
+	timer, err = NewTimer(dir)
+	assert.NoError(t, err)
+
+	timer.Start()
+	defer timer.Stop()
+	<-time.After(time.Millisecond * 15)
+	assert.Equal(t, time.Duration(0), timer.Time())
+	assert.NotEqual(t, time.Duration(0), timer.OffHours())
+
+	timer.Reset()
+	<-time.After(time.Millisecond * 2)
+	assert.True(t, timer.OffHours() <= timer.timerData.MBU)
+}
+
+func TestSessionLimit(t *testing.T) {
+	dir := setupTestProject(t)
+	writeProjectFile(t, dir, "timeglass.json", `{"mbu": "5ms", "limits": {"session": "10ms"}}`)
+
+	timer, err := NewTimer(dir)
+	assert.NoError(t, err)
+
+	timer.Start()
+	defer timer.Stop()
+	<-time.After(time.Millisecond * 15)
+
+	assert.True(t, timer.IsPaused())
+	assert.Contains(t, timer.Held(), "the maximum session is 10ms")
+	assert.Equal(t, timer.Time(), timer.HeldTime())
+	if assert.Len(t, timer.Events(), 1) {
+		assert.Equal(t, EventLimitReached, timer.Events()[0].Type)
+		assert.Equal(t, "session", timer.Events()[0].Data["limit"])
+	}
+
+	//activity doesn't wake up a held timer
+	timer.Unpause()
+	assert.True(t, timer.IsPaused())
+
+	timer.Confirm(false)
+	assert.Equal(t, "", timer.Held())
+	assert.Equal(t, time.Duration(0), timer.Time())
+
+	timer.Unpause()
+	assert.False(t, timer.IsPaused())
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+
+	"github.com/timeglass/glass/config"
+)
+
+// webhooks that don't configure batching use these defaults
+var (
+	DefaultWebhookBatchSize     = 50
+	DefaultWebhookBatchInterval = time.Second * 5
+)
+
+var (
+	//failed posts are retried after a delay that doubles
+	//with each attempt, until the maximum number of attempts
+	WebhookRetryDelay   = time.Second * 10
+	WebhookMaxRetry     = time.Hour
+	WebhookMaxAttempts  = 12
+	WebhookMaxQueueSize = 5000
+
+	//the header that holds the signature of a payload
+	WebhookSignatureHeader = "X-Timeglass-Signature"
+)
+
+// a single event that is yet to be posted to a webhook
+type delivery struct {
+	Webhook  *config.Webhook `json:"webhook"`
+	Event    *Event          `json:"event"`
+	Attempts int             `json:"attempts"`
+	Due      time.Time       `json:"due"`
+}
+
+// Sink posts the events of all timers to their webhooks, events are
+// kept in a queue that is stored next to the ledger such that they
+// survive a restart of the service and can be retried when posting fails
+type Sink struct {
+	path   string
+	queue  []*delivery
+	mu     sync.Mutex
+	client *http.Client
+	wake   chan struct{}
+	stop   chan struct{}
+	once   sync.Once
+}
+
+func NewSink(path string) (*Sink, error) {
+	s := &Sink{
+		path:   path,
+		queue:  []*delivery{},
+		client: &http.Client{Timeout: time.Second * 30},
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+
+	return s, s.Load()
+}
+
+// Add queues the event for each of the webhooks it is posted to
+func (s *Sink) Add(webhooks []*config.Webhook, ev *Event) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	added := false
+	for _, wh := range webhooks {
+		if wh.URL == "" || !wh.RunsOn(ev.Type) {
+			continue
+		}
+
+		interval := time.Duration(wh.BatchInterval)
+		if interval <= 0 {
+			interval = DefaultWebhookBatchInterval
+		}
+
+		s.queue = append(s.queue, &delivery{Webhook: wh, Event: ev, Due: time.Now().Add(interval)})
+		added = true
+	}
+
+	if len(s.queue) > WebhookMaxQueueSize {
+		log.Printf("Webhook queue is full, dropping the %d oldest event(s)", len(s.queue)-WebhookMaxQueueSize)
+		s.queue = s.queue[len(s.queue)-WebhookMaxQueueSize:]
+	}
+
+	s.mu.Unlock()
+	if !added {
+		return
+	}
+
+	s.save()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stop ends Start, it doesn't block when the sink
+// was never started and can be called more than once
+func (s *Sink) Stop() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+// Start posts events as they become due until the sink is stopped
+func (s *Sink) Start() {
+	for {
+		s.Flush()
+
+		wait := time.Minute
+		s.mu.Lock()
+		for _, d := range s.queue {
+			if until := d.Due.Sub(time.Now()); until < wait {
+				wait = until
+			}
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Flush posts the events of every webhook that has an event that is due,
+// events that came in later are sent along unless they are being retried
+func (s *Sink) Flush() {
+	s.mu.Lock()
+	now := time.Now()
+	batches := map[string][]*delivery{}
+	keys := []string{}
+	for _, d := range s.queue {
+		key := batchKey(d.Webhook)
+		if !d.Due.After(now) && batches[key] == nil {
+			batches[key] = []*delivery{}
+			keys = append(keys, key)
+		}
+	}
+
+	for _, d := range s.queue {
+		key := batchKey(d.Webhook)
+		size := d.Webhook.BatchSize
+		if size <= 0 {
+			size = DefaultWebhookBatchSize
+		}
+
+		b, ok := batches[key]
+		if !ok || len(b) >= size || (d.Attempts > 0 && d.Due.After(now)) {
+			continue
+		}
+
+		batches[key] = append(b, d)
+	}
+	s.mu.Unlock()
+
+	if len(keys) == 0 {
+		return
+	}
+
+	for _, key := range keys {
+		batch := batches[key]
+		url := string(batch[0].Webhook.URL)
+		err := s.post(batch)
+		s.mu.Lock()
+		if err == nil {
+			s.remove(batch)
+		} else {
+			log.Printf("Failed to post %d event(s) to webhook '%s': %s", len(batch), url, err)
+			for _, d := range batch {
+				d.Attempts++
+				d.Due = time.Now().Add(retryDelay(d.Attempts))
+			}
+
+			dropped := []*delivery{}
+			for _, d := range batch {
+				if d.Attempts >= WebhookMaxAttempts {
+					dropped = append(dropped, d)
+				}
+			}
+
+			if len(dropped) > 0 {
+				log.Printf("Giving up on %d event(s) for webhook '%s' after %d attempts", len(dropped), url, WebhookMaxAttempts)
+				s.remove(dropped)
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	s.save()
+}
+
+// events are only batched with those of webhooks that have the same
+// url and secret, such that each batch is signed with the right secret
+func batchKey(wh *config.Webhook) string {
+	return string(wh.URL) + "\x00" + wh.Secret
+}
+
+// the delay before an attempt to post is retried
+func retryDelay(attempts int) time.Duration {
+	delay := WebhookRetryDelay
+	for i := 1; i < attempts && delay < WebhookMaxRetry; i++ {
+		delay *= 2
+	}
+
+	if delay > WebhookMaxRetry {
+		return WebhookMaxRetry
+	}
+
+	return delay
+}
+
+// removes deliveries from the queue, the lock must be held
+func (s *Sink) remove(ds []*delivery) {
+	gone := map[*delivery]bool{}
+	for _, d := range ds {
+		gone[d] = true
+	}
+
+	queue := []*delivery{}
+	for _, d := range s.queue {
+		if !gone[d] {
+			queue = append(queue, d)
+		}
+	}
+
+	s.queue = queue
+}
+
+// posts a batch of events of a single webhook
+func (s *Sink) post(batch []*delivery) error {
+	events := []*Event{}
+	for _, d := range batch {
+		events = append(events, d.Event)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"events": events})
+	if err != nil {
+		return errwrap.Wrapf("Failed to serialize events: {{err}}", err)
+	}
+
+	wh := batch[0].Webhook
+	req, err := http.NewRequest("POST", string(wh.URL), bytes.NewReader(body))
+	if err != nil {
+		return errwrap.Wrapf("Failed to create request: {{err}}", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("Timeglass/%s", Version))
+	if wh.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, Sign(wh.Secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response '%s'", resp.Status)
+	}
+
+	return nil
+}
+
+// Sign returns the signature of a payload: the hex encoded
+// HMAC-SHA256 of the body using the secret, e.g: 'sha256=3f2a...'
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Pending returns the number of events that are yet to be posted
+func (s *Sink) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+func (s *Sink) Load() error {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to open '%s': {{err}}", s.path), err)
+	}
+
+	err = json.Unmarshal(data, &s.queue)
+	if err != nil {
+		s.queue = []*delivery{}
+		return errwrap.Wrapf(fmt.Sprintf("Failed to decode JSON in '%s': {{err}}", s.path), err)
+	}
+
+	return nil
+}
+
+// writes the queue to disk, it holds the secrets of
+// the webhooks so it's only readable by the service
+func (s *Sink) save() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.Marshal(s.queue)
+	if err != nil {
+		log.Printf("Failed to serialize webhook queue: %s", err)
+		return
+	}
+
+	err = ioutil.WriteFile(s.path, data, 0600)
+	if err != nil {
+		log.Printf("Failed to save webhook queue to '%s': %s", s.path, err)
+	}
+}
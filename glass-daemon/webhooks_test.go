@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timeglass/glass/config"
+)
+
+type receiver struct {
+	sync.Mutex
+	fail     int
+	bodies   [][]byte
+	sigs     []string
+	attempts int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.Lock()
+	defer rc.Unlock()
+
+	rc.attempts++
+	if rc.fail > 0 {
+		rc.fail--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	rc.bodies = append(rc.bodies, body)
+	rc.sigs = append(rc.sigs, r.Header.Get(WebhookSignatureHeader))
+}
+
+func (rc *receiver) received() int {
+	rc.Lock()
+	defer rc.Unlock()
+	return len(rc.bodies)
+}
+
+func TestWebhookBatches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_webhooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rc := &receiver{}
+	svr := httptest.NewServer(rc)
+	defer svr.Close()
+
+	sink, err := NewSink(filepath.Join(dir, "webhooks.json"))
+	assert.NoError(t, err)
+	go sink.Start()
+	defer sink.Stop()
+
+	wh := &config.Webhook{URL: config.WebhookURL(svr.URL), Secret: "s3cret", BatchInterval: config.Duration(time.Millisecond * 30)}
+	other := &config.Webhook{URL: config.WebhookURL(svr.URL + "/other"), Events: []config.HookEvent{"timer.reset"}}
+	for i := 0; i < 3; i++ {
+		sink.Add([]*config.Webhook{wh, other}, &Event{Type: EventTimerPaused, Dir: dir, Message: fmt.Sprintf("pause %d", i)})
+	}
+
+	for i := 0; i < 200 && rc.received() == 0; i++ {
+		<-time.After(time.Millisecond * 5)
+	}
+
+	//all events end up in a single signed post
+	assert.Equal(t, 1, rc.received())
+	payload := struct{ Events []*Event }{}
+	assert.NoError(t, json.Unmarshal(rc.bodies[0], &payload))
+	if assert.Len(t, payload.Events, 3) {
+		assert.Equal(t, EventTimerPaused, payload.Events[0].Type)
+		assert.Equal(t, "pause 2", payload.Events[2].Message)
+	}
+
+	assert.Equal(t, Sign("s3cret", rc.bodies[0]), rc.sigs[0])
+	assert.Equal(t, 0, sink.Pending())
+}
+
+func TestWebhookBatchesPerSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_webhooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rc := &receiver{}
+	svr := httptest.NewServer(rc)
+	defer svr.Close()
+
+	sink, err := NewSink(filepath.Join(dir, "webhooks.json"))
+	assert.NoError(t, err)
+
+	//two webhooks with the same url but different secrets and events
+	a := &config.Webhook{URL: config.WebhookURL(svr.URL), Secret: "a", BatchInterval: config.Duration(time.Millisecond)}
+	b := &config.Webhook{URL: config.WebhookURL(svr.URL), Secret: "b", Events: []config.HookEvent{"timer.reset"}, BatchInterval: config.Duration(time.Millisecond)}
+	sink.Add([]*config.Webhook{a, b}, &Event{Type: EventTimerPaused, Dir: dir})
+	sink.Add([]*config.Webhook{a, b}, &Event{Type: EventTimerReset, Dir: dir})
+	<-time.After(time.Millisecond * 2)
+	sink.Flush()
+
+	//each post holds the events of one webhook and is signed with its secret
+	if assert.Equal(t, 2, rc.received()) {
+		for i, secret := range []string{"a", "b"} {
+			assert.Equal(t, Sign(secret, rc.bodies[i]), rc.sigs[i])
+		}
+
+		payload := struct{ Events []*Event }{}
+		assert.NoError(t, json.Unmarshal(rc.bodies[0], &payload))
+		assert.Len(t, payload.Events, 2)
+		assert.NoError(t, json.Unmarshal(rc.bodies[1], &payload))
+		if assert.Len(t, payload.Events, 1) {
+			assert.Equal(t, EventTimerReset, payload.Events[0].Type)
+		}
+	}
+
+	assert.Equal(t, 0, sink.Pending())
+}
+
+func TestSinkStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_webhooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	//stopping doesn't wait for a sink that never started
+	sink, err := NewSink(filepath.Join(dir, "webhooks.json"))
+	assert.NoError(t, err)
+	sink.Stop()
+	sink.Stop()
+
+	done := make(chan struct{})
+	sink, err = NewSink(filepath.Join(dir, "webhooks.json"))
+	assert.NoError(t, err)
+	go func() {
+		sink.Start()
+		close(done)
+	}()
+
+	sink.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sink didn't stop")
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_webhooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	delay := WebhookRetryDelay
+	WebhookRetryDelay = time.Millisecond * 10
+	defer func() { WebhookRetryDelay = delay }()
+
+	rc := &receiver{fail: 1}
+	svr := httptest.NewServer(rc)
+	defer svr.Close()
+
+	path := filepath.Join(dir, "webhooks.json")
+	sink, err := NewSink(path)
+	assert.NoError(t, err)
+
+	wh := &config.Webhook{URL: config.WebhookURL(svr.URL), BatchInterval: config.Duration(time.Millisecond)}
+	sink.Add([]*config.Webhook{wh}, &Event{Type: EventTimerReset, Dir: dir})
+	<-time.After(time.Millisecond * 2)
+	sink.Flush()
+	assert.Equal(t, 1, rc.attempts)
+	assert.Equal(t, 0, rc.received())
+
+	//the queue survives a restart and is retried after a delay
+	sink, err = NewSink(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sink.Pending())
+	sink.Flush()
+	assert.Equal(t, 1, rc.attempts)
+
+	<-time.After(time.Millisecond * 15)
+	sink.Flush()
+	assert.Equal(t, 1, rc.received())
+	assert.Equal(t, "", rc.sigs[0])
+	assert.Equal(t, 0, sink.Pending())
+	assert.Equal(t, time.Hour, retryDelay(20))
+}
+
+func TestTimerWebhooks(t *testing.T) {
+	dir := setupTestProject(t)
+	userdir, err := ioutil.TempDir("", "glass_webhooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(userdir)
+
+	writeProjectFile(t, userdir, "timeglass.json", `{"hooks": {"webhooks": [{"url": "http://localhost:1/events", "events": ["timer.started"]}]}}`)
+	sink, err := NewSink(filepath.Join(userdir, "webhooks.json"))
+	assert.NoError(t, err)
+
+	timer, err := NewTimer(dir)
+	assert.NoError(t, err)
+	timer.SetUserDir(userdir)
+	timer.SetSink(sink)
+
+	timer.Start()
+	defer timer.Stop()
+	timer.Pause()
+	assert.Equal(t, 1, sink.Pending())
+}
@@ -37,18 +37,27 @@ func main() {
 	}
 
 	cmds := []Command{
-		command.NewInstall(),   //install daemon and start service
-		command.NewUninstall(), //stop daemon and uninstall service
-		command.NewInit(),      //write hooks, create timer and pull time data
-		command.NewStart(),     //create timer for current directory, start measuring
-		command.NewPause(),     //pause timer for the current directory, restart on file activity
-		command.NewStatus(),    //fetch info of the timer for the current directory
-		command.NewReset(),     //reset the timer to 0s
-		command.NewStop(),      //remove timer for current directory, discarding meaurement
-		command.NewPush(),      //push notes branch to remote
-		command.NewPull(),      //pull notes branch from remote
-		command.NewPunch(),     //persist time measurement to current HEAD commit
-		command.NewSum(),       //sum total time of each commit given
+		command.NewInstall(),    //install daemon and start service
+		command.NewUninstall(),  //stop daemon and uninstall service
+		command.NewInit(),       //write hooks, create timer and pull time data
+		command.NewDeinit(),     //remove the timeglass parts of the hooks
+		command.NewStart(),      //create timer for current directory, start measuring
+		command.NewPause(),      //pause timer for the current directory, restart on file activity
+		command.NewHeartbeat(),  //activity without writing files, e.g: reading code in an editor
+		command.NewStatus(),     //fetch info of the timer for the current directory
+		command.NewReset(),      //reset the timer to 0s
+		command.NewStop(),       //remove timer for current directory, discarding meaurement
+		command.NewPush(),       //push notes branch to remote
+		command.NewPull(),       //pull notes branch from remote
+		command.NewPunch(),      //persist time measurement to current HEAD commit
+		command.NewSum(),        //sum total time of each commit given
+		command.NewLog(),        //report time of each commit in a revision range
+		command.NewExport(),     //export time of each commit as a timesheet
+		command.NewIssues(),     //report time per issue key
+		command.NewNotes(),      //maintain time data notes, e.g migrating to a new format
+		command.NewEstimate(),   //estimate the time of a branch or issue
+		command.NewCheckpoint(), //register time in a directory without version control
+		command.NewConfig(),     //show and change the configuration
 	}
 
 	for _, c := range cmds {
@@ -0,0 +1,131 @@
+package vcs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+)
+
+const ESTIMATE_PREFIX = "estimate "
+
+// Estimate is the time that is expected to be spent on a
+// branch or issue, a zero budget marks a removed estimate
+// such that the removal is kept when estimates are merged
+type Estimate struct {
+	Key    string
+	Budget time.Duration
+	At     time.Time
+	By     string
+}
+
+// the key of an estimate for the given branch
+func BranchKey(branch string) string { return "branch:" + branch }
+
+// the key of an estimate for the given issue, e.g: "PROJ-12"
+func IssueKey(issue string) string { return "issue:" + issue }
+
+// returns the kind ('branch' or 'issue') and name of the key
+func (e *Estimate) Target() (string, string) {
+	parts := strings.SplitN(e.Key, ":", 2)
+	if len(parts) != 2 {
+		return "", e.Key
+	}
+
+	return parts[0], parts[1]
+}
+
+func (e *Estimate) String() string {
+	return strings.Join([]string{
+		"key=" + url.PathEscape(e.Key),
+		"budget=" + e.Budget.String(),
+		"at=" + e.At.UTC().Format(time.RFC3339Nano),
+		"by=" + url.PathEscape(e.By),
+	}, " ")
+}
+
+// ParseEstimates reads estimates that were written by FormatEstimates,
+// unknown lines are skipped just like they are for notes
+func ParseEstimates(r io.Reader) (map[string]*Estimate, error) {
+	res := map[string]*Estimate{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, ESTIMATE_PREFIX) {
+			continue
+		}
+
+		e := &Estimate{}
+		for _, field := range strings.Fields(line[len(ESTIMATE_PREFIX):]) {
+			parts := strings.SplitN(field, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+
+			var err error
+			switch parts[0] {
+			case "key":
+				e.Key, err = url.PathUnescape(parts[1])
+			case "budget":
+				e.Budget, err = time.ParseDuration(parts[1])
+			case "at":
+				e.At, err = time.Parse(time.RFC3339Nano, parts[1])
+			case "by":
+				e.By, err = url.PathUnescape(parts[1])
+			}
+
+			if err != nil {
+				return nil, errwrap.Wrapf(fmt.Sprintf("Invalid value for field '%s' in line '%s': {{err}}", parts[0], line), err)
+			}
+		}
+
+		if e.Key != "" {
+			res[e.Key] = e
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errwrap.Wrapf("Failed to scan estimates: {{err}}", err)
+	}
+
+	return res, nil
+}
+
+// FormatEstimates writes estimates sorted by key, such that
+// the same estimates always result in the same content
+func FormatEstimates(estimates map[string]*Estimate) string {
+	keys := []string{}
+	for k := range estimates {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	buff := bytes.NewBuffer(nil)
+	for _, k := range keys {
+		fmt.Fprintf(buff, "%s%s\n", ESTIMATE_PREFIX, estimates[k])
+	}
+
+	return buff.String()
+}
+
+// MergeEstimates combines two sets of estimates, for
+// keys that are in both the most recent change wins
+func MergeEstimates(a, b map[string]*Estimate) map[string]*Estimate {
+	res := map[string]*Estimate{}
+	for k, e := range a {
+		res[k] = e
+	}
+
+	for k, e := range b {
+		if curr, ok := res[k]; !ok || e.At.After(curr.At) {
+			res[k] = e
+		}
+	}
+
+	return res
+}
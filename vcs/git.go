@@ -8,8 +8,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
@@ -17,12 +17,14 @@ import (
 
 var TimeSpentNotesRef = "time-spent"
 
-const (
-	TOTAL_PREFIX = "total="
-)
+// estimates are kept in a file of a commit that this
+// ref points to, it is pushed and pulled with the notes
+var EstimatesRef = "refs/timeglass/estimates"
+var estimatesFile = "estimates"
 
-var PrepCommitTmpl = template.Must(template.New("name").Parse(`#!/bin/sh
-# only add time to template and message sources
+// the Timeglass parts of the hooks, they are installed
+// next to anything else the hooks already do
+var PrepCommitHook = `# only add time to template and message sources
 # @see http://git-scm.com/docs/githooks#_prepare_commit_msg
 case "$2" in
 message|template) 
@@ -32,41 +34,99 @@ message|template)
 	# interactive method
 	printf "$(glass -s status --commit-template)\n$(cat $1)" > "$1" ;;	
 esac
-`))
 
-var PostCommitTmpl = template.Must(template.New("name").Parse(`#!/bin/sh
-#persist (punch) to newly created commit and reset the timer
-glass -s status -t "{{"{{"}}.{{"}}"}}" | glass punch
-glass reset
-`))
+# record the time in a trailer as well, if one is configured
+case "$2" in
+message|template|"")
+	TRAILER="$(glass -s status --trailer)"
+	if [ -n "$TRAILER" ]; then
+		# the message might lack a final newline, git strips blank lines
+		echo >> "$1"
+		git interpret-trailers --in-place --trailer "$TRAILER" "$1"
+	fi ;;
+esac
 
-var PrePushTmpl = template.Must(template.New("name").Parse(`#!/bin/sh
-#push time data
-glass push $1
-`))
+# mention estimates that crossed a warning threshold on
+# the terminal and, when editing, as a comment in the message
+WARNINGS="$(glass -s status --warnings)"
+if [ -n "$WARNINGS" ]; then
+	echo "$WARNINGS" >&2
+	if [ -z "$2" ]; then
+		echo "$WARNINGS" >> "$1"
+	fi
+fi
+`
 
-type gitTimeData struct {
-	total time.Duration
-}
+var PostCommitHook = `#persist (punch) the time of the timers of the project and
+#its sub-projects to the newly created commit and reset them,
+#timers are left alone when their time couldn't be punched
+glass punch --timers && glass reset
+`
 
-func (g *gitTimeData) Total() time.Duration { return g.total }
+var PrePushHook = `#push time data
+glass push $1
+`
 
 type Git struct {
-	dir  string
-	root string
-	init string
+	dir     string
+	common  string
+	root    string
+	init    string
+	trailer string
 }
 
 func NewGit(dir string) *Git {
 	return &Git{
-		init: dir,
+		init:    dir,
+		trailer: DefaultTrailer,
 	}
 }
 
+// SetTrailer changes the key of the commit message trailer
+// that is read when a commit has no time data in the notes
+func (g *Git) SetTrailer(key string) {
+	g.trailer = key
+}
+
 func (g *Git) Name() string { return "git" }
+
+// returns a git command that runs in the
+// directory the Git instance was created for
+func (g *Git) command(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.init
+	return cmd
+}
+
+// runs a git command with the given input and returns its trimmed
+// output, the error includes whatever git wrote to stderr
+func (g *Git) run(stdin string, args ...string) (string, error) {
+	outbuff := bytes.NewBuffer(nil)
+	errbuff := bytes.NewBuffer(nil)
+	cmd := g.command(args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = outbuff
+	cmd.Stderr = errbuff
+
+	err := cmd.Run()
+	if err != nil {
+		return "", errwrap.Wrapf(fmt.Sprintf("Failed to run git command %s (%s): {{err}}", args, strings.TrimSpace(errbuff.String())), err)
+	}
+
+	return strings.TrimSpace(outbuff.String()), nil
+}
+
+func (g *Git) hasRef(ref string) bool {
+	_, err := g.run("", "rev-parse", "--verify", "-q", ref+"^{commit}")
+	return err == nil
+}
+
+// IsAvailable also resolves where git keeps its data, in linked worktrees
+// and submodules '.git' is a file that points elsewhere. The common dir
+// is shared by all worktrees of a repository and holds the hooks
 func (g *Git) IsAvailable() bool {
 	outbuff := bytes.NewBuffer(nil)
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd := g.command("rev-parse", "--show-toplevel", "--git-dir", "--git-common-dir")
 	cmd.Stdout = outbuff
 
 	err := cmd.Run()
@@ -74,18 +134,90 @@ func (g *Git) IsAvailable() bool {
 		return false
 	}
 
-	g.root = strings.TrimSpace(outbuff.String())
-	g.dir = filepath.Join(g.root, ".git")
+	lines := strings.Split(strings.TrimSpace(outbuff.String()), "\n")
+	if len(lines) != 3 {
+		return false
+	}
+
+	//both dirs are relative to the working directory when inside it
+	abs := func(p string) string {
+		if !filepath.IsAbs(p) {
+			return filepath.Join(g.init, p)
+		}
+
+		return filepath.Clean(p)
+	}
+
+	g.root = lines[0]
+	g.dir = abs(lines[1])
+	g.common = abs(lines[2])
 	return true
 }
 
+// Superproject returns the root of the repository that this
+// repository is a submodule of, or an empty string if it isn't
+func (g *Git) Superproject() (string, error) {
+	out, err := g.run("", "rev-parse", "--show-superproject-working-tree")
+	if err != nil {
+		return "", errwrap.Wrapf("Failed to determine superproject: {{err}}", err)
+	}
+
+	return out, nil
+}
+
+// Submodules lists the directories of all (recursive) submodules
+func (g *Git) Submodules() ([]string, error) {
+	dirs := []string{}
+
+	//older versions of git only list submodules from the root
+	outbuff := bytes.NewBuffer(nil)
+	cmd := g.command("submodule", "--quiet", "foreach", "--recursive", `echo "$toplevel/$sm_path"`)
+	cmd.Dir = g.root
+	cmd.Stdout = outbuff
+	err := cmd.Run()
+	if err != nil {
+		return nil, errwrap.Wrapf("Failed to list submodules: {{err}}", err)
+	}
+
+	for _, dir := range strings.Split(outbuff.String(), "\n") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+	}
+
+	return dirs, nil
+}
+
+// Worktrees lists the linked worktrees that were
+// created inside the root of this worktree
+func (g *Git) Worktrees() ([]string, error) {
+	dirs := []string{}
+	out, err := g.run("", "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, errwrap.Wrapf("Failed to list worktrees: {{err}}", err)
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.HasPrefix(line, "worktree ") {
+			continue
+		}
+
+		dir := filepath.Clean(line[len("worktree "):])
+		if dir != g.root && strings.HasPrefix(dir, g.root+string(filepath.Separator)) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs, nil
+}
+
 func (g *Git) Root() string {
 	return g.root
 }
 
 func (g *Git) DefaultRemote() (string, error) {
 	outbuff := bytes.NewBuffer(nil)
-	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}")
+	cmd := g.command("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}")
 	cmd.Stdout = outbuff
 
 	err := cmd.Run()
@@ -100,16 +232,27 @@ func (g *Git) DefaultRemote() (string, error) {
 }
 
 func (g *Git) Show(commit string) (TimeData, error) {
-	data := &gitTimeData{}
+	data := NewNote()
 	args := []string{"notes", "--ref=" + TimeSpentNotesRef, "show", commit}
 	outbuff := bytes.NewBuffer(nil)
 	errbuff := bytes.NewBuffer(nil)
-	cmd := exec.Command("git", args...)
+	cmd := g.command(args...)
 	cmd.Stdout = outbuff
 	cmd.Stderr = errbuff
 
 	err := cmd.Run()
-	if err != nil && strings.Contains(errbuff.String(), "No note found for object") {
+	if err != nil && strings.Contains(strings.ToLower(errbuff.String()), "no note found for object") {
+
+		//the time might have been recorded in the message instead
+		trailers, err := g.showTrailers([]string{commit})
+		if err != nil {
+			return data, err
+		}
+
+		for _, td := range trailers {
+			return td, nil
+		}
+
 		return data, ErrNoCommitTimeData
 	}
 
@@ -123,48 +266,488 @@ func (g *Git) Show(commit string) (TimeData, error) {
 		return data, errwrap.Wrapf(fmt.Sprintf("Failed to show time for commit '%s' using git args %s: {{err}}", commit, args), err)
 	}
 
-	//scan lines in note
+	data, err = ParseNote(outbuff)
+	if err != nil {
+		return data, errwrap.Wrapf(fmt.Sprintf("Failed to parse note for commit '%s': {{err}}", commit), err)
+	}
+
+	return data, nil
+}
+
+// ShowAll reads the time data of many commits at once, commits without
+// a note fall back to the time in the trailers of their message.
+// Commits should be given as full hashes, commits without time
+// data are absent from the result
+func (g *Git) ShowAll(commits []string) (map[string]TimeData, error) {
+	res, err := g.showNotes(commits)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []string{}
+	for _, c := range commits {
+		if _, ok := res[c]; !ok {
+			missing = append(missing, c)
+		}
+	}
+
+	trailers, err := g.showTrailers(missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for c, td := range trailers {
+		res[c] = td
+	}
+
+	return res, nil
+}
+
+// showTrailers reads the time in the trailers of the messages of the given
+// commits using a single git process, commits without it are left out
+func (g *Git) showTrailers(commits []string) (map[string]TimeData, error) {
+	res := map[string]TimeData{}
+	if len(commits) == 0 || g.trailer == "" {
+		return res, nil
+	}
+
+	args := []string{"log", "--no-walk=unsorted", "--stdin", "--format=%H%n%B%x00"}
+	outbuff := bytes.NewBuffer(nil)
+	cmd := g.command(args...)
+	cmd.Stdin = strings.NewReader(strings.Join(commits, "\n") + "\n")
+	cmd.Stdout = outbuff
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to read commit messages using git args %s: {{err}}", args), err)
+	}
+
+	for _, record := range strings.Split(outbuff.String(), "\x00") {
+		parts := strings.SplitN(strings.TrimLeft(record, "\n"), "\n", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		d, ok := ParseTrailers(parts[1], g.trailer)
+		if !ok {
+			continue
+		}
+
+		//there is no way to tell who spent the time, like notes
+		//of the first version it is attributed to the author
+		res[parts[0]] = &Note{version: 1, total: d}
+	}
+
+	return res, nil
+}
+
+// showNotes lists the notes tree once and streams the notes through a
+// single git process instead of forking git for every commit
+func (g *Git) showNotes(commits []string) (map[string]TimeData, error) {
+	res := map[string]TimeData{}
+	args := []string{"notes", "--ref=" + TimeSpentNotesRef, "list"}
+	outbuff := bytes.NewBuffer(nil)
+	cmd := g.command(args...)
+	cmd.Stdout = outbuff
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to list notes using git args %s: {{err}}", args), err)
+	}
+
+	//each line holds the note blob followed by the annotated commit
+	blobs := map[string]string{}
+	for _, line := range strings.Split(outbuff.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			blobs[fields[1]] = fields[0]
+		}
+	}
+
+	requested := []string{}
+	for _, c := range commits {
+		if _, ok := blobs[c]; ok {
+			requested = append(requested, c)
+		}
+	}
+
+	if len(requested) == 0 {
+		return res, nil
+	}
+
+	//write all blob hashes while reading the output concurrently, a
+	//large input could otherwise fill the pipes and deadlock
+	args = []string{"cat-file", "--batch"}
+	cmd = g.command(args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errwrap.Wrapf("Failed to open stdin of git cat-file: {{err}}", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errwrap.Wrapf("Failed to open stdout of git cat-file: {{err}}", err)
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to start git command %s: {{err}}", args), err)
+	}
+
+	//the process (and the writer) shouldn't outlive a failed read
+	waited := false
+	defer func() {
+		if !waited {
+			cmd.Process.Kill()
+			cmd.Wait()
+		}
+	}()
+
+	go func() {
+		defer stdin.Close()
+		for _, c := range requested {
+			if _, err := fmt.Fprintln(stdin, blobs[c]); err != nil {
+				return
+			}
+		}
+	}()
+
+	r := bufio.NewReader(stdout)
+	for _, c := range requested {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("Failed to read note header for commit '%s': {{err}}", c), err)
+		}
+
+		//header: <sha> <type> <size>, or '<sha> missing'
+		fields := strings.Fields(header)
+		if len(fields) == 2 && fields[1] == "missing" {
+			fmt.Fprintf(os.Stderr, "Skipping note of commit '%s', its blob %s is missing\n", c, fields[0])
+			continue
+		}
+
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("Unexpected output from git cat-file for commit '%s': %s", c, header)
+		}
+
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("Failed to parse note size for commit '%s': {{err}}", c), err)
+		}
+
+		//content is followed by a newline
+		content := make([]byte, size+1)
+		_, err = io.ReadFull(r, content)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("Failed to read note for commit '%s': {{err}}", c), err)
+		}
+
+		//a single bad note doesn't spoil the time of all other commits
+		note, err := ParseNote(bytes.NewReader(content[:size]))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping note of commit '%s' that can't be parsed: %s\n", c, err)
+			continue
+		}
+
+		res[c] = note
+	}
+
+	waited = true
+	err = cmd.Wait()
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to run git command %s: {{err}}", args), err)
+	}
+
+	return res, nil
+}
+
+// Persist changes the entry of an author in the note of the given commit
+// using the provided mode, entries of other authors are kept and
+// an audit line is appended that describes the change
+func (g *Git) Persist(commit string, mode PunchMode, entry *TimeEntry, message string) error {
+	by, err := g.configValue("user.email")
+	if err != nil {
+		return errwrap.Wrapf("Failed to determine author email: {{err}}", err)
+	}
+
+	if entry.Author == "" {
+		entry.Author = by
+	}
+
+	note, err := g.note(commit)
+	if err != nil {
+		return err
+	}
+
+	err = note.Apply(mode, entry, by, message)
+	if err != nil {
+		return err
+	}
+
+	return g.writeNote(commit, note)
+}
+
+// MigrateNotes rewrites all notes that are not in the current
+// format, it returns the number of notes that were upgraded
+func (g *Git) MigrateNotes() (int, error) {
+	args := []string{"notes", "--ref=" + TimeSpentNotesRef, "list"}
+	outbuff := bytes.NewBuffer(nil)
+	cmd := g.command(args...)
+	cmd.Stdout = outbuff
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return 0, errwrap.Wrapf(fmt.Sprintf("Failed to list notes using git args %s: {{err}}", args), err)
+	}
+
+	count := 0
 	scanner := bufio.NewScanner(outbuff)
 	for scanner.Scan() {
-		line := scanner.Text()
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
 
-		//@todo for now only read total line
-		if strings.HasPrefix(line, TOTAL_PREFIX) {
-			t, err := time.ParseDuration(line[len(TOTAL_PREFIX):])
-			if err != nil {
-				return data, errwrap.Wrapf(fmt.Sprintf("Failed to parse time from line '%s': {{err}}"), err)
-			}
+		data, err := g.Show(fields[1])
+		if err != nil {
+			return count, err
+		}
 
-			data.total = t
+		note := data.(*Note)
+		author, err := g.commitAuthor(fields[1])
+		if err != nil {
+			return count, err
 		}
+
+		if !note.Upgrade(author) {
+			continue
+		}
+
+		err = g.writeNote(fields[1], note)
+		if err != nil {
+			return count, err
+		}
+
+		count++
 	}
 	if err := scanner.Err(); err != nil {
-		return data, errwrap.Wrapf(fmt.Sprintf("Failed to scan note for commit '%s': {{err}}"), err)
+		return count, errwrap.Wrapf("Failed to scan note list: {{err}}", err)
 	}
 
-	return data, nil
+	return count, nil
 }
 
-func (g *Git) Persist(t time.Duration) error {
-	args := []string{"notes", "--ref=" + TimeSpentNotesRef, "add", "-f", "-m", fmt.Sprintf("%s%s", TOTAL_PREFIX, t)}
-	cmd := exec.Command("git", args...)
+// Log lists commits that are selected by the query (e.g "v0.5.0..HEAD"
+// since yesterday), newest first. Each commit is attributed to the
+// (local) branch it can be reached from
+func (g *Git) Log(q *Query) ([]*Commit, error) {
+	revs := q.Revs
+	if len(revs) == 0 && !q.All {
+		revs = []string{"HEAD"}
+	}
+
+	//records are separated by NUL and fields by the unit
+	//separator such that messages can contain newlines
+	args := []string{"log", "-z", "--format=%H%x1f%at%x1f%ae%x1f%an%x1f%s%x1f%b"}
+	if q.NoWalk {
+		args = append(args, "--no-walk=unsorted")
+	}
+
+	if q.Since != "" {
+		args = append(args, "--since="+q.Since)
+	}
+
+	if q.Until != "" {
+		args = append(args, "--until="+q.Until)
+	}
+
+	if q.Author != "" {
+		args = append(args, "--author="+q.Author)
+	}
+
+	if q.Grep != "" {
+		args = append(args, "--fixed-strings", "--grep="+q.Grep)
+	}
+
+	//the notes themselves are stored in commits
+	//that should never be part of the selection
+	if q.All {
+		args = append(args, "--exclude=refs/notes/*", "--exclude=refs/timeglass/*", "--all")
+	}
+
+	//revisions are read from stdin, there can be more of
+	//them (e.g: piped in) than fit on a command line
+	args = append(args, "--stdin", "--")
+	args = append(args, q.Paths...)
+	outbuff := bytes.NewBuffer(nil)
+	errbuff := bytes.NewBuffer(nil)
+	cmd := g.command(args...)
+	cmd.Stdin = strings.NewReader(strings.Join(revs, "\n") + "\n")
+	cmd.Stdout = outbuff
+	cmd.Stderr = errbuff
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to list commits of %d revision(s) using git args %s (%s): {{err}}", len(revs), args, strings.TrimSpace(errbuff.String())), err)
+	}
+
+	commits := []*Commit{}
+	for _, record := range strings.Split(outbuff.String(), "\x00") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x1f", 6)
+		if len(fields) != 6 {
+			continue
+		}
+
+		ts, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("Failed to parse date of commit '%s': {{err}}", fields[0]), err)
+		}
+
+		commits = append(commits, &Commit{
+			Hash:       fields[0],
+			Date:       time.Unix(ts, 0),
+			Author:     fields[2],
+			AuthorName: fields[3],
+			Subject:    fields[4],
+			Body:       strings.TrimSpace(fields[5]),
+		})
+	}
+
+	if q.Branches {
+		err = g.nameBranches(commits)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return commits, nil
+}
+
+// names the local branch each commit can be reached from
+// (e.g "master~2" is attributed to "master"), commits are
+// named in chunks to keep the argument list reasonable
+func (g *Git) nameBranches(commits []*Commit) error {
+	chunk := 500
+	for i := 0; i < len(commits); i += chunk {
+		part := commits[i:]
+		if len(part) > chunk {
+			part = part[:chunk]
+		}
+
+		args := []string{"name-rev", "--name-only", "--refs=refs/heads/*"}
+		for _, c := range part {
+			args = append(args, c.Hash)
+		}
+
+		outbuff := bytes.NewBuffer(nil)
+		cmd := g.command(args...)
+		cmd.Stdout = outbuff
+
+		err := cmd.Run()
+		if err != nil {
+			return errwrap.Wrapf("Failed to name branches of commits: {{err}}", err)
+		}
+
+		names := strings.Split(strings.TrimSpace(outbuff.String()), "\n")
+		for j, c := range part {
+			if j >= len(names) || names[j] == "undefined" {
+				continue
+			}
+
+			name := names[j]
+			if idx := strings.IndexAny(name, "~^"); idx > -1 {
+				name = name[:idx]
+			}
+
+			c.Branch = strings.TrimPrefix(name, "heads/")
+		}
+	}
+
+	return nil
+}
+
+// returns the note of a commit in the current format, legacy
+// notes are upgraded and an empty note is returned if the
+// commit has no time data yet
+func (g *Git) note(commit string) (*Note, error) {
+	data, err := g.Show(commit)
+	if err == ErrNoCommitTimeData {
+		return NewNote(), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	note := data.(*Note)
+	if note.Version() < NoteVersion {
+		author, err := g.commitAuthor(commit)
+		if err != nil {
+			return nil, err
+		}
+
+		note.Upgrade(author)
+	}
+
+	return note, nil
+}
+
+func (g *Git) writeNote(commit string, note *Note) error {
+	args := []string{"notes", "--ref=" + TimeSpentNotesRef, "add", "-f", "-F", "-", commit}
+	cmd := g.command(args...)
+	cmd.Stdin = strings.NewReader(note.String())
 	err := cmd.Run()
 	if err != nil {
-		return errwrap.Wrapf(fmt.Sprintf("Failed to persist time '%s' using git command %s: {{err}}", t, args), err)
+		return errwrap.Wrapf(fmt.Sprintf("Failed to write note for '%s' using git command %s: {{err}}", commit, args), err)
 	}
 
 	return nil
 }
 
+func (g *Git) commitAuthor(commit string) (string, error) {
+	args := []string{"log", "-1", "--format=%ae", commit}
+	outbuff := bytes.NewBuffer(nil)
+	cmd := g.command(args...)
+	cmd.Stdout = outbuff
+
+	err := cmd.Run()
+	if err != nil {
+		return "", errwrap.Wrapf(fmt.Sprintf("Failed to determine author of '%s' using git command %s: {{err}}", commit, args), err)
+	}
+
+	return strings.TrimSpace(outbuff.String()), nil
+}
+
+func (g *Git) configValue(key string) (string, error) {
+	args := []string{"config", "--get", key}
+	outbuff := bytes.NewBuffer(nil)
+	cmd := g.command(args...)
+	cmd.Stdout = outbuff
+
+	err := cmd.Run()
+	if err != nil {
+		return "", errwrap.Wrapf(fmt.Sprintf("Failed to read git config using git command %s: {{err}}", args), err)
+	}
+
+	return strings.TrimSpace(outbuff.String()), nil
+}
+
 func (g *Git) Pull(remote string) error {
+	err := g.pullEstimates(remote)
+	if err != nil {
+		return err
+	}
+
 	args := []string{"fetch", remote, fmt.Sprintf("refs/notes/%s:refs/notes/%s", TimeSpentNotesRef, TimeSpentNotesRef)}
-	cmd := exec.Command("git", args...)
+	cmd := g.command(args...)
 	buff := bytes.NewBuffer(nil)
 
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = buff
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil && strings.Contains(buff.String(), "Couldn't find remote ref") {
 		return ErrNoRemoteTimeData
 	}
@@ -185,21 +768,29 @@ func (g *Git) Pull(remote string) error {
 func (g *Git) Push(remote string, refs string) error {
 
 	//if time ref is already pushed, dont do it again
-	if strings.Contains(refs, TimeSpentNotesRef) {
+	if strings.Contains(refs, TimeSpentNotesRef) || strings.Contains(refs, EstimatesRef) {
 		return nil
 	}
 
-	args := []string{"push", remote, fmt.Sprintf("refs/notes/%s", TimeSpentNotesRef)}
-	cmd := exec.Command("git", args...)
+	//only push refs that exist locally
+	args := []string{"push", remote}
+	for _, ref := range []string{"refs/notes/" + TimeSpentNotesRef, EstimatesRef} {
+		if g.hasRef(ref) {
+			args = append(args, ref)
+		}
+	}
+
+	if len(args) == 2 {
+		return ErrNoLocalTimeData
+	}
+
+	cmd := g.command(args...)
 	buff := bytes.NewBuffer(nil)
 
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = buff
 
 	err := cmd.Run()
-	if err != nil && strings.Contains(buff.String(), "src refspec refs/notes/"+TimeSpentNotesRef+" does not match any") {
-		return ErrNoLocalTimeData
-	}
 
 	//in other cases present user with git output
 	_, err2 := io.Copy(os.Stderr, buff)
@@ -214,59 +805,292 @@ func (g *Git) Push(remote string, refs string) error {
 	return nil
 }
 
-func (g *Git) Hook() error {
-	hpath := filepath.Join(g.dir, "hooks")
+// Estimates returns all estimates, including removed ones
+func (g *Git) Estimates() (map[string]*Estimate, error) {
+	return g.readEstimates(EstimatesRef)
+}
 
-	//prepare commit msg: status()
-	prepcopath := filepath.Join(hpath, "prepare-commit-msg")
-	prepcof, err := os.Create(prepcopath)
+// SetEstimate records the estimate in a new commit of the estimates ref
+func (g *Git) SetEstimate(e *Estimate) error {
+	by, err := g.configValue("user.email")
 	if err != nil {
-		return errwrap.Wrapf(fmt.Sprintf("Failed to create prepare-commit-msg  '%s': {{err}}", prepcof.Name()), err)
+		return errwrap.Wrapf("Failed to determine author email: {{err}}", err)
 	}
 
-	err = os.Chmod(prepcopath, 0766)
+	estimates, err := g.Estimates()
 	if err != nil {
-		return errwrap.Wrapf(fmt.Sprintf("Failed to make prepare-commit-msg file '%s' executable: {{err}}", hpath), err)
+		return err
 	}
 
-	err = PrepCommitTmpl.Execute(prepcof, struct{}{})
+	e.By = by
+	e.At = time.Now()
+	estimates[e.Key] = e
+
+	parents := []string{}
+	if g.hasRef(EstimatesRef) {
+		parents = append(parents, EstimatesRef)
+	}
+
+	return g.writeEstimates(estimates, fmt.Sprintf("Estimate %s at %s", e.Key, e.Budget), parents...)
+}
+
+// CurrentBranch returns the name of the checked out
+// branch or an empty string if HEAD is detached
+func (g *Git) CurrentBranch() (string, error) {
+	out, err := g.run("", "symbolic-ref", "--short", "-q", "HEAD")
 	if err != nil {
-		return errwrap.Wrapf("Failed to run post-commit template: {{err}}", err)
+		if _, err2 := g.run("", "rev-parse", "-q", "--verify", "HEAD"); err2 == nil {
+			return "", nil
+		}
+
+		return "", errwrap.Wrapf("Failed to determine current branch: {{err}}", err)
 	}
 
-	//post commit: lap()
-	postcopath := filepath.Join(hpath, "post-commit")
-	postcof, err := os.Create(postcopath)
+	return out, nil
+}
+
+// BranchCommits lists the hashes of commits on the given
+// branch that can't be reached from any other local branch
+func (g *Git) BranchCommits(branch string) ([]string, error) {
+	out, err := g.run("", "rev-list", "refs/heads/"+branch, "--not", "--exclude=refs/heads/"+branch, "--branches")
 	if err != nil {
-		return errwrap.Wrapf(fmt.Sprintf("Failed to create post-commit '%s': {{err}}", postcof.Name()), err)
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to list commits unique to branch '%s': {{err}}", branch), err)
+	}
+
+	if out == "" {
+		return []string{}, nil
+	}
+
+	return strings.Split(out, "\n"), nil
+}
+
+func (g *Git) readEstimates(ref string) (map[string]*Estimate, error) {
+	if !g.hasRef(ref) {
+		return map[string]*Estimate{}, nil
 	}
 
-	err = os.Chmod(postcopath, 0766)
+	out, err := g.run("", "cat-file", "blob", ref+":"+estimatesFile)
 	if err != nil {
-		return errwrap.Wrapf(fmt.Sprintf("Failed to make post-commit file '%s' executable: {{err}}", hpath), err)
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to read estimates from '%s': {{err}}", ref), err)
 	}
 
-	err = PostCommitTmpl.Execute(postcof, struct{}{})
+	return ParseEstimates(strings.NewReader(out))
+}
+
+// writes the estimates as a tree with a single file and
+// points the estimates ref to a new commit of that tree
+func (g *Git) writeEstimates(estimates map[string]*Estimate, message string, parents ...string) error {
+	blob, err := g.run(FormatEstimates(estimates), "hash-object", "-w", "--stdin")
 	if err != nil {
-		return errwrap.Wrapf("Failed to run post-commit template: {{err}}", err)
+		return errwrap.Wrapf("Failed to write estimates: {{err}}", err)
 	}
 
-	//post receive: push()
-	prepushpath := filepath.Join(hpath, "pre-push")
-	prepushf, err := os.Create(prepushpath)
+	tree, err := g.run(fmt.Sprintf("100644 blob %s\t%s\n", blob, estimatesFile), "mktree")
 	if err != nil {
-		return errwrap.Wrapf(fmt.Sprintf("Failed to create pre-push  '%s': {{err}}", prepushf.Name()), err)
+		return errwrap.Wrapf("Failed to write estimates tree: {{err}}", err)
+	}
+
+	args := []string{"commit-tree", tree, "-m", message}
+	for _, p := range parents {
+		args = append(args, "-p", p)
 	}
 
-	err = os.Chmod(prepushpath, 0766)
+	commit, err := g.run("", args...)
 	if err != nil {
-		return errwrap.Wrapf(fmt.Sprintf("Failed to make pre-push file '%s' executable: {{err}}", hpath), err)
+		return errwrap.Wrapf("Failed to commit estimates: {{err}}", err)
 	}
 
-	err = PrePushTmpl.Execute(prepushf, struct{}{})
+	_, err = g.run("", "update-ref", EstimatesRef, commit)
 	if err != nil {
-		return errwrap.Wrapf("Failed to run pre-push template: {{err}}", err)
+		return errwrap.Wrapf("Failed to update estimates ref: {{err}}", err)
 	}
 
 	return nil
 }
+
+// fetches the estimates of the remote and merges them
+// with the local estimates, the most recent change of
+// each estimate wins
+func (g *Git) pullEstimates(remote string) error {
+	//remotes can also be urls, which aren't valid in refs
+	name := strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+
+		return '_'
+	}, remote)
+
+	tracking := fmt.Sprintf("refs/timeglass/remotes/%s/estimates", name)
+	_, err := g.run("", "fetch", remote, fmt.Sprintf("+%s:%s", EstimatesRef, tracking))
+	if err != nil {
+		if strings.Contains(err.Error(), "Couldn't find remote ref") {
+			return nil
+		}
+
+		return errwrap.Wrapf(fmt.Sprintf("Failed to fetch estimates from remote '%s': {{err}}", remote), err)
+	}
+
+	if !g.hasRef(EstimatesRef) {
+		_, err = g.run("", "update-ref", EstimatesRef, tracking)
+		return err
+	}
+
+	//nothing to do if the remote estimates are already merged
+	if _, err := g.run("", "merge-base", "--is-ancestor", tracking, EstimatesRef); err == nil {
+		return nil
+	}
+
+	local, err := g.readEstimates(EstimatesRef)
+	if err != nil {
+		return err
+	}
+
+	theirs, err := g.readEstimates(tracking)
+	if err != nil {
+		return err
+	}
+
+	return g.writeEstimates(MergeEstimates(local, theirs), "Merge estimates from "+remote, EstimatesRef, tracking)
+}
+
+// the directory that git runs hooks from and whether the hooks in it are
+// scripts of a hook manager that are kept in the repository (husky)
+func (g *Git) hooksDir() (string, bool, error) {
+	hpath, err := g.configValue("core.hooksPath")
+	if err != nil || hpath == "" {
+		return filepath.Join(g.common, "hooks"), false, nil
+	}
+
+	if strings.HasPrefix(hpath, "~/") {
+		home := os.Getenv("HOME")
+		hpath = filepath.Join(home, hpath[2:])
+	} else if !filepath.IsAbs(hpath) {
+		hpath = filepath.Join(g.root, hpath)
+	}
+
+	//husky (v9) generates the hooks in '.husky/_' which call
+	//the scripts in '.husky', only those are meant to be edited
+	if filepath.Base(hpath) == "_" && filepath.Base(filepath.Dir(hpath)) == ".husky" {
+		return filepath.Dir(hpath), true, nil
+	}
+
+	return hpath, filepath.Base(hpath) == ".husky", nil
+}
+
+// Hook installs the Timeglass parts of the hooks, existing hooks (e.g
+// of linters or the pre-commit framework) keep working as before
+func (g *Git) Hook() ([]*Change, error) {
+	hpath, inline, err := g.hooksDir()
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(hpath, 0755)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to create hooks directory '%s': {{err}}", hpath), err)
+	}
+
+	changes := []*Change{}
+	for _, h := range []struct {
+		name  string
+		block string
+		stdin bool
+	}{
+		{"prepare-commit-msg", PrepCommitHook, false},
+		{"post-commit", PostCommitHook, false},
+		{"pre-push", PrePushHook, true},
+	} {
+		change, err := InstallHook(filepath.Join(hpath, h.name), h.block, h.stdin, inline)
+		if err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// Unhook removes the Timeglass parts of the hooks and puts
+// back the hooks that were wrapped, leaving everything else
+func (g *Git) Unhook(dry bool) ([]*Change, error) {
+	hpath, _, err := g.hooksDir()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []*Change{}
+	for _, name := range []string{"prepare-commit-msg", "post-commit", "pre-push"} {
+		change, err := UninstallHook(filepath.Join(hpath, name), dry)
+		if err != nil {
+			return nil, err
+		}
+
+		if change != nil {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+// Cleanup removes the configuration and references that Timeglass
+// added to the repository: the 'timeglass' config section, the notes
+// ref as display ref and the estimates fetched from remotes. The time
+// data itself is only removed when 'data' is set
+func (g *Git) Cleanup(dry, data bool) ([]*Change, error) {
+	changes := []*Change{}
+	do := func(c *Change, args ...string) error {
+		changes = append(changes, c)
+		if dry {
+			return nil
+		}
+
+		_, err := g.run("", args...)
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("Failed to %s '%s': {{err}}", c.Action, c.Path), err)
+		}
+
+		return nil
+	}
+
+	if out, _ := g.run("", "config", "--local", "--get-regexp", `^timeglass\.`); out != "" {
+		if err := do(&Change{"git config timeglass.*", "unset"}, "config", "--local", "--remove-section", "timeglass"); err != nil {
+			return nil, err
+		}
+	}
+
+	ref := "refs/notes/" + TimeSpentNotesRef
+	if out, _ := g.run("", "config", "--local", "--get-all", "notes.displayRef"); strings.Contains(out, ref) {
+		if err := do(&Change{"git config notes.displayRef " + ref, "unset"}, "config", "--local", "--unset-all", "notes.displayRef", "^"+ref+"$"); err != nil {
+			return nil, err
+		}
+	}
+
+	refs := []string{}
+	out, err := g.run("", "for-each-ref", "--format=%(refname)", "refs/timeglass/remotes/")
+	if err != nil {
+		return nil, errwrap.Wrapf("Failed to list fetched estimates: {{err}}", err)
+	}
+
+	if out != "" {
+		refs = append(refs, strings.Split(out, "\n")...)
+	}
+
+	if data {
+		for _, r := range []string{ref, EstimatesRef} {
+			if g.hasRef(r) {
+				refs = append(refs, r)
+			}
+		}
+	}
+
+	for _, r := range refs {
+		if err := do(&Change{r, "delete"}, "update-ref", "-d", r); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
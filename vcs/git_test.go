@@ -0,0 +1,255 @@
+package vcs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// creates a scratch repository with the given number of
+// commits of which every other commit has time data
+func setupGitRepo(t testing.TB, n int) (*Git, []string) {
+	dir, err := ioutil.TempDir("", "glass_git")
+	assert.NoError(t, err)
+
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %s, %s", args, err, out)
+		}
+
+		return strings.TrimSpace(string(out))
+	}
+
+	run("init", "-q")
+	run("config", "user.email", "glass@example.com")
+	run("config", "user.name", "Glass")
+
+	commits := []string{}
+	for i := 0; i < n; i++ {
+		run("commit", "-q", "--allow-empty", "-m", fmt.Sprintf("commit %d", i))
+		commits = append(commits, run("rev-parse", "HEAD"))
+		if i%2 == 0 {
+			run("notes", "--ref="+TimeSpentNotesRef, "add", "-m", fmt.Sprintf("total=%dm", i+1))
+		}
+	}
+
+	g := NewGit(dir)
+	assert.True(t, g.IsAvailable())
+	return g, commits
+}
+
+func TestShowAll(t *testing.T) {
+	g, commits := setupGitRepo(t, 6)
+	defer os.RemoveAll(g.Root())
+
+	all, err := g.ShowAll(commits)
+	assert.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	for i, c := range commits {
+		data, err := g.Show(c)
+		if i%2 != 0 {
+			assert.Equal(t, ErrNoCommitTimeData, err)
+			assert.Nil(t, all[c])
+			continue
+		}
+
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(i+1)*time.Minute, data.Total())
+		assert.Equal(t, data.Total(), all[c].Total())
+	}
+}
+
+func BenchmarkShow(b *testing.B) {
+	g, commits := setupGitRepo(b, 100)
+	defer os.RemoveAll(g.Root())
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, c := range commits {
+			_, err := g.Show(c)
+			if err != nil && err != ErrNoCommitTimeData {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkShowAll(b *testing.B) {
+	g, commits := setupGitRepo(b, 100)
+	defer os.RemoveAll(g.Root())
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := g.ShowAll(commits)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestShowAllSkipsBadNotes(t *testing.T) {
+	g, commits := setupGitRepo(t, 4)
+	defer os.RemoveAll(g.Root())
+	cmd := exec.Command("git", "notes", "--ref="+TimeSpentNotesRef, "add", "-f", "-m", "total=banana", commits[2])
+	cmd.Dir = g.Root()
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+
+	all, err := g.ShowAll(commits)
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+	if assert.NotNil(t, all[commits[0]]) {
+		assert.Equal(t, time.Minute, all[commits[0]].Total())
+	}
+}
+
+func TestLog(t *testing.T) {
+	g, commits := setupGitRepo(t, 6)
+	defer os.RemoveAll(g.Root())
+
+	log, err := g.Log(&Query{Revs: []string{commits[1] + ".." + commits[4]}})
+	assert.NoError(t, err)
+	if assert.Len(t, log, 3) {
+		assert.Equal(t, commits[4], log[0].Hash)
+		assert.Equal(t, "commit 4", log[0].Subject)
+		assert.Equal(t, "", log[0].Branch)
+	}
+
+	//branches are only named when asked for
+	log, err = g.Log(&Query{Revs: []string{commits[4]}, Branches: true})
+	assert.NoError(t, err)
+	if assert.Len(t, log, 5) {
+		assert.NotEqual(t, "", log[0].Branch)
+	}
+
+	//more revisions than fit on a command line, e.g: piped in
+	revs := []string{}
+	for len(revs) < 60000 {
+		revs = append(revs, commits...)
+	}
+
+	log, err = g.Log(&Query{Revs: revs, NoWalk: true})
+	assert.NoError(t, err)
+	assert.Len(t, log, 6)
+
+	_, err = g.Log(&Query{Revs: append(revs, "nosuchrev")})
+	if assert.Error(t, err) {
+		assert.True(t, len(err.Error()) < 1000)
+	}
+}
+
+func TestEstimatesPushPull(t *testing.T) {
+	g1, _ := setupGitRepo(t, 2)
+	defer os.RemoveAll(g1.Root())
+
+	//the other clone has no time notes of its own that would diverge
+	g2, _ := setupGitRepo(t, 0)
+	defer os.RemoveAll(g2.Root())
+
+	remote, err := ioutil.TempDir("", "glass_remote")
+	assert.NoError(t, err)
+	assert.NoError(t, exec.Command("git", "init", "-q", "--bare", remote).Run())
+
+	assert.NoError(t, g1.SetEstimate(&Estimate{Key: BranchKey("feature"), Budget: 6 * time.Hour}))
+	assert.NoError(t, g1.Push(remote, ""))
+
+	//both sides change estimates before pulling
+	assert.NoError(t, g2.SetEstimate(&Estimate{Key: IssueKey("PROJ-1"), Budget: time.Hour}))
+	assert.NoError(t, g2.Pull(remote))
+	assert.NoError(t, g2.SetEstimate(&Estimate{Key: BranchKey("feature"), Budget: 8 * time.Hour}))
+	assert.NoError(t, g2.Push(remote, ""))
+
+	assert.NoError(t, g1.Pull(remote))
+	estimates, err := g1.Estimates()
+	assert.NoError(t, err)
+	assert.Len(t, estimates, 2)
+	assert.Equal(t, 8*time.Hour, estimates[BranchKey("feature")].Budget)
+	assert.Equal(t, time.Hour, estimates[IssueKey("PROJ-1")].Budget)
+}
+
+func TestCleanup(t *testing.T) {
+	g, _ := setupGitRepo(t, 2)
+	defer os.RemoveAll(g.Root())
+	assert.NoError(t, g.SetEstimate(&Estimate{Key: BranchKey("master"), Budget: time.Hour}))
+
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = g.Root()
+		assert.NoError(t, cmd.Run())
+	}
+
+	run("config", "timeglass.example", "true")
+	run("config", "--add", "notes.displayRef", "refs/notes/commits")
+	run("config", "--add", "notes.displayRef", "refs/notes/"+TimeSpentNotesRef)
+	run("update-ref", "refs/timeglass/remotes/origin/estimates", EstimatesRef)
+
+	changes, err := g.Cleanup(true, false)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 3)
+	assert.True(t, g.hasRef("refs/timeglass/remotes/origin/estimates"))
+
+	changes, err = g.Cleanup(false, true)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 5)
+
+	for _, ref := range []string{"refs/timeglass/remotes/origin/estimates", EstimatesRef, "refs/notes/" + TimeSpentNotesRef} {
+		assert.False(t, g.hasRef(ref), ref)
+	}
+
+	display, err := g.configValue("notes.displayRef")
+	assert.NoError(t, err)
+	assert.Equal(t, "refs/notes/commits", display)
+
+	_, err = g.configValue("timeglass.example")
+	assert.Error(t, err)
+}
+
+func TestTrailers(t *testing.T) {
+	g, commits := setupGitRepo(t, 2)
+	defer os.RemoveAll(g.Root())
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = g.Root()
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+
+	assert.Equal(t, "Time-Spent: 1h20m", FormatTrailer("Time-Spent", 80*time.Minute))
+	assert.Equal(t, "Time-Spent: 2h", FormatTrailer("Time-Spent", 2*time.Hour))
+	assert.Equal(t, "Time-Spent: 45s", FormatTrailer("Time-Spent", 45*time.Second))
+
+	//squashed commits carry a trailer each
+	run("commit", "-q", "--allow-empty", "-m", "squashed\n\nfixes the login\n\nTime-Spent: 1h20m\ntime-spent: 10m\nSigned-off-by: Glass <glass@example.com>")
+	squashed := run("rev-parse", "HEAD")
+	run("commit", "-q", "--allow-empty", "-m", "mentions Time-Spent: 5m\n\nbut has no trailer")
+	untracked := run("rev-parse", "HEAD")
+
+	data, err := g.Show(squashed)
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, data.Total())
+
+	_, err = g.Show(untracked)
+	assert.Equal(t, ErrNoCommitTimeData, err)
+
+	//notes take precedence over trailers
+	all, err := g.ShowAll(append(commits, squashed, untracked))
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+	assert.Equal(t, time.Minute, all[commits[0]].Total())
+	assert.Equal(t, 90*time.Minute, all[squashed].Total())
+
+	g.SetTrailer("Spent")
+	_, err = g.Show(squashed)
+	assert.Equal(t, ErrNoCommitTimeData, err)
+}
@@ -0,0 +1,828 @@
+package vcs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+)
+
+// time data is kept in files on a dedicated named branch such
+// that it travels with regular pushes and pulls without ever
+// being part of the history of the code itself
+var HgNotesBranch = "timeglass"
+
+var hgHooks = []string{
+	//there is no way to change the message, show warnings instead
+	"precommit.timeglass = glass -s status --warnings >&2; true",
+	`commit.timeglass = glass punch --timers -c "$HG_NODE" && glass reset`,
+	//the push of the outgoing changesets holds the repository lock, time
+	//data is pushed in the background as soon as the lock is released
+	`outgoing.timeglass = [ "$HG_SOURCE" != "push" ] || [ -n "$TIMEGLASS_PUSH" ] || (glass push "$HG_URL" > /dev/null 2>&1 &)`,
+}
+
+type Hg struct {
+	root string
+	init string
+}
+
+func NewHg(dir string) *Hg {
+	return &Hg{
+		init: dir,
+	}
+}
+
+func (h *Hg) Name() string { return "hg" }
+
+// returns a hg command that runs in the given directory, output
+// is not affected by any user configuration due to HGPLAIN and
+// the outgoing hook knows it doesn't need to push time data
+func (h *Hg) command(dir string, args ...string) *exec.Cmd {
+	cmd := exec.Command("hg", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "HGPLAIN=1", "TIMEGLASS_PUSH=1")
+	return cmd
+}
+
+// a revset of all commits on the notes branch, in a form
+// that doesn't fail when the branch doesn't exist (yet)
+func hgNotesRevset() string {
+	return "branch(" + strconv.Quote("re:^"+regexp.QuoteMeta(HgNotesBranch)+"$") + ")"
+}
+
+// runs a hg command in the given directory and returns its trimmed
+// output, the error includes whatever hg wrote to stderr
+func (h *Hg) run(dir string, args ...string) (string, error) {
+	outbuff := bytes.NewBuffer(nil)
+	errbuff := bytes.NewBuffer(nil)
+	cmd := h.command(dir, args...)
+	cmd.Stdout = outbuff
+	cmd.Stderr = errbuff
+
+	err := cmd.Run()
+	if err != nil {
+		return "", errwrap.Wrapf(fmt.Sprintf("Failed to run hg command %s (%s): {{err}}", args, strings.TrimSpace(errbuff.String())), err)
+	}
+
+	return strings.TrimSpace(outbuff.String()), nil
+}
+
+func (h *Hg) IsAvailable() bool {
+	root, err := h.run(h.init, "root")
+	if err != nil {
+		return false
+	}
+
+	h.root = root
+	return true
+}
+
+func (h *Hg) Root() string {
+	return h.root
+}
+
+func (h *Hg) DefaultRemote() (string, error) {
+	_, err := h.run(h.root, "paths", "default")
+	if err != nil {
+		return "", ErrNoRemote
+	}
+
+	return "default", nil
+}
+
+// resolves a revision to its full hash, git style revisions
+// (e.g "HEAD~1") are translated the same way as in Log
+func (h *Hg) node(rev string) (string, error) {
+	set, err := hgRev(rev)
+	if err != nil {
+		return "", err
+	}
+
+	node, err := h.run(h.root, "log", "-r", set, "--template", "{node}")
+	if err != nil || node == "" {
+		return "", fmt.Errorf("Unknown revision '%s': %s", rev, err)
+	}
+
+	return node, nil
+}
+
+// the email address of the configured user
+func (h *Hg) username() (string, error) {
+	user, err := h.run(h.root, "config", "ui.username")
+	if err != nil || user == "" {
+		return "", fmt.Errorf("No username configured, set 'username' in the [ui] section of your hgrc")
+	}
+
+	if start, end := strings.Index(user, "<"), strings.LastIndex(user, ">"); start > -1 && end > start {
+		return user[start+1 : end], nil
+	}
+
+	return user, nil
+}
+
+func (h *Hg) hasNotesBranch(dir string) bool {
+	out, err := h.run(dir, "log", "-r", hgNotesRevset(), "-l", "1", "--template", "{node}")
+	return err == nil && out != ""
+}
+
+// reads a file from the head of the notes branch in the given repository
+func (h *Hg) readFile(dir, path string) (string, bool, error) {
+	if !h.hasNotesBranch(dir) {
+		return "", false, nil
+	}
+
+	files, err := h.run(dir, "manifest", "-r", HgNotesBranch)
+	if err != nil {
+		return "", false, errwrap.Wrapf("Failed to list time data: {{err}}", err)
+	}
+
+	found := false
+	for _, f := range strings.Split(files, "\n") {
+		if f == path {
+			found = true
+		}
+	}
+
+	if !found {
+		return "", false, nil
+	}
+
+	outbuff := bytes.NewBuffer(nil)
+	cmd := h.command(dir, "cat", "-r", HgNotesBranch, path)
+	cmd.Stdout = outbuff
+	err = cmd.Run()
+	if err != nil {
+		return "", false, errwrap.Wrapf(fmt.Sprintf("Failed to read '%s' from the notes branch: {{err}}", path), err)
+	}
+
+	return outbuff.String(), true, nil
+}
+
+func (h *Hg) Show(commit string) (TimeData, error) {
+	node, err := h.node(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	content, ok, err := h.readFile(h.root, "notes/"+node)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return NewNote(), ErrNoCommitTimeData
+	}
+
+	note, err := ParseNote(strings.NewReader(content))
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to parse note for commit '%s': {{err}}", commit), err)
+	}
+
+	return note, nil
+}
+
+// ShowAll reads the notes of all commits with a single hg cat
+// that writes each note to a temporary directory
+func (h *Hg) ShowAll(commits []string) (map[string]TimeData, error) {
+	res := map[string]TimeData{}
+	if !h.hasNotesBranch(h.root) {
+		return res, nil
+	}
+
+	files, err := h.run(h.root, "manifest", "-r", HgNotesBranch)
+	if err != nil {
+		return nil, errwrap.Wrapf("Failed to list time data: {{err}}", err)
+	}
+
+	noted := map[string]bool{}
+	for _, f := range strings.Split(files, "\n") {
+		if strings.HasPrefix(f, "notes/") {
+			noted[f[len("notes/"):]] = true
+		}
+	}
+
+	tmp, err := ioutil.TempDir("", "timeglass_hg")
+	if err != nil {
+		return nil, errwrap.Wrapf("Failed to create temporary directory: {{err}}", err)
+	}
+
+	defer os.RemoveAll(tmp)
+	requested := []string{}
+	for _, c := range commits {
+		if noted[c] {
+			requested = append(requested, c)
+		}
+	}
+
+	//notes are written in chunks to keep the argument list reasonable
+	chunk := 500
+	for i := 0; i < len(requested); i += chunk {
+		part := requested[i:]
+		if len(part) > chunk {
+			part = part[:chunk]
+		}
+
+		args := []string{"cat", "-r", HgNotesBranch, "-o", filepath.Join(tmp, "%s")}
+		for _, c := range part {
+			args = append(args, "notes/"+c)
+		}
+
+		_, err = h.run(h.root, args...)
+		if err != nil {
+			return nil, errwrap.Wrapf("Failed to read time data: {{err}}", err)
+		}
+	}
+
+	for _, c := range requested {
+		f, err := os.Open(filepath.Join(tmp, c))
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("Failed to open note for commit '%s': {{err}}", c), err)
+		}
+
+		note, err := ParseNote(f)
+		f.Close()
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("Failed to parse note for commit '%s': {{err}}", c), err)
+		}
+
+		res[c] = note
+	}
+
+	return res, nil
+}
+
+// changes the notes branch in a private clone of the repository, as
+// hg can only commit to the branch that is checked out, and pushes
+// the result back. Heads of the branch that were pulled from others
+// are merged first, for files that were changed on both sides
+// the lines of both versions are kept
+func (h *Hg) updateNotes(message string, fn func(dir string) error) error {
+	work := filepath.Join(h.root, ".hg", "timeglass")
+	if _, err := os.Stat(work); os.IsNotExist(err) {
+		_, err = h.run(h.root, "clone", "-U", h.root, work)
+		if err != nil {
+			return errwrap.Wrapf("Failed to create clone for time data: {{err}}", err)
+		}
+	}
+
+	user, err := h.run(h.root, "config", "ui.username")
+	if err != nil || user == "" {
+		user = "timeglass"
+	}
+
+	if h.hasNotesBranch(h.root) {
+		_, err = h.run(work, "pull", "-b", HgNotesBranch, h.root)
+		if err != nil {
+			return errwrap.Wrapf("Failed to pull time data into clone: {{err}}", err)
+		}
+
+		_, err = h.run(work, "update", "-C", HgNotesBranch)
+		if err != nil {
+			return errwrap.Wrapf("Failed to check out time data: {{err}}", err)
+		}
+
+		heads, err := h.run(work, "heads", "--template", "{node}\n", HgNotesBranch)
+		if err != nil {
+			return errwrap.Wrapf("Failed to list heads of time data: {{err}}", err)
+		}
+
+		current, err := h.run(work, "log", "-r", ".", "--template", "{node}")
+		if err != nil {
+			return errwrap.Wrapf("Failed to determine checked out time data: {{err}}", err)
+		}
+
+		for _, head := range strings.Split(heads, "\n") {
+			if head == current {
+				continue
+			}
+
+			_, err = h.run(work, "merge", "--tool", "internal:union", "-r", head)
+			if err != nil {
+				return errwrap.Wrapf("Failed to merge time data: {{err}}", err)
+			}
+
+			_, err = h.run(work, "commit", "-u", user, "-m", "Merge time data")
+			if err != nil {
+				return errwrap.Wrapf("Failed to commit merged time data: {{err}}", err)
+			}
+		}
+	} else {
+		_, err = h.run(work, "update", "-C", "null")
+		if err != nil {
+			return errwrap.Wrapf("Failed to prepare time data: {{err}}", err)
+		}
+
+		_, err = h.run(work, "branch", "-f", HgNotesBranch)
+		if err != nil {
+			return errwrap.Wrapf("Failed to create branch for time data: {{err}}", err)
+		}
+	}
+
+	if fn != nil {
+		err = fn(work)
+		if err != nil {
+			return err
+		}
+
+		_, err = h.run(work, "addremove")
+		if err != nil {
+			return errwrap.Wrapf("Failed to add time data: {{err}}", err)
+		}
+
+		//nothing to commit is reported as a failure, check first
+		status, err := h.run(work, "status")
+		if err != nil {
+			return errwrap.Wrapf("Failed to check for changed time data: {{err}}", err)
+		}
+
+		if status != "" {
+			_, err = h.run(work, "commit", "-u", user, "-m", message)
+			if err != nil {
+				return errwrap.Wrapf("Failed to commit time data: {{err}}", err)
+			}
+		}
+	}
+
+	//exit code 1 means there was nothing to push
+	cmd := h.command(work, "push", "-f", "--new-branch", "-b", HgNotesBranch, h.root)
+	err = cmd.Run()
+	if exiterr, ok := err.(*exec.ExitError); err != nil && (!ok || !strings.Contains(exiterr.Error(), "exit status 1")) {
+		return errwrap.Wrapf("Failed to store time data in repository: {{err}}", err)
+	}
+
+	return nil
+}
+
+func (h *Hg) writeNote(dir, node string, note *Note) error {
+	err := os.MkdirAll(filepath.Join(dir, "notes"), 0755)
+	if err != nil {
+		return errwrap.Wrapf("Failed to create notes directory: {{err}}", err)
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, "notes", node), []byte(note.String()), 0644)
+}
+
+// Persist changes the entry of an author in the note of the given commit
+// using the provided mode, just like it does for git
+func (h *Hg) Persist(commit string, mode PunchMode, entry *TimeEntry, message string) error {
+	by, err := h.username()
+	if err != nil {
+		return err
+	}
+
+	if entry.Author == "" {
+		entry.Author = by
+	}
+
+	node, err := h.node(commit)
+	if err != nil {
+		return err
+	}
+
+	return h.updateNotes(fmt.Sprintf("Time spent on %s", node[:12]), func(dir string) error {
+		note := NewNote()
+		data, err := ioutil.ReadFile(filepath.Join(dir, "notes", node))
+		if err == nil {
+			note, err = ParseNote(bytes.NewReader(data))
+			if err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("Failed to parse note for commit '%s': {{err}}", commit), err)
+			}
+		}
+
+		err = note.Apply(mode, entry, by, message)
+		if err != nil {
+			return err
+		}
+
+		return h.writeNote(dir, node, note)
+	})
+}
+
+// MigrateNotes upgrades notes that are not in the current format, notes
+// are only ever written by this backend in the current format
+func (h *Hg) MigrateNotes() (int, error) {
+	count := 0
+	if !h.hasNotesBranch(h.root) {
+		return count, nil
+	}
+
+	err := h.updateNotes("Migrate time data", func(dir string) error {
+		files, err := ioutil.ReadDir(filepath.Join(dir, "notes"))
+		if err != nil {
+			return errwrap.Wrapf("Failed to list notes: {{err}}", err)
+		}
+
+		for _, fi := range files {
+			data, err := ioutil.ReadFile(filepath.Join(dir, "notes", fi.Name()))
+			if err != nil {
+				return err
+			}
+
+			note, err := ParseNote(bytes.NewReader(data))
+			if err != nil {
+				return err
+			}
+
+			author, err := h.run(h.root, "log", "-r", fi.Name(), "--template", "{author|email}")
+			if err != nil {
+				return err
+			}
+
+			if note.Upgrade(author) {
+				count++
+				err = h.writeNote(dir, fi.Name(), note)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// ancestry suffixes of git style revisions, e.g: the '~2' of 'HEAD~2'
+var hgAncestry = regexp.MustCompile(`^(.*?)((?:[~^][0-9]*)*)$`)
+var hgAncestryOp = regexp.MustCompile(`[~^][0-9]*`)
+
+// translates a single git style revision (e.g "HEAD~2" or "v0.5.0^")
+// into a revset, hg knows the same ancestry operators as git but the
+// revision they apply to is quoted such that any name can be used
+func hgRev(rev string) (string, error) {
+	m := hgAncestry.FindStringSubmatch(rev)
+	base, suffix := m[1], m[2]
+	for _, unsupported := range []string{"@{", ":/", "^", "~"} {
+		if strings.Contains(base, unsupported) {
+			return "", fmt.Errorf("Revision '%s' is not supported with Mercurial, use ids, names, 'HEAD', '~N', '^N' or ranges such as 'A..B'", rev)
+		}
+	}
+
+	if base == "" || base == "HEAD" {
+		base = "."
+	}
+
+	set := strconv.Quote(base)
+	for _, op := range hgAncestryOp.FindAllString(suffix, -1) {
+		switch {
+		case len(op) == 1:
+			//'~' and '^' are the first parent, like in git
+			set += op + "1"
+		case op[0] == '^' && op != "^0" && op != "^1" && op != "^2":
+			return "", fmt.Errorf("Revision '%s' is not supported with Mercurial, commits have at most two parents", rev)
+		default:
+			set += op
+		}
+	}
+
+	return set, nil
+}
+
+// translates a git style revision (e.g "v0.5.0..HEAD") into a revset,
+// negative revisions (e.g "^master") are returned separately. Forms
+// that hg has no equivalent for (e.g "A...B") are refused
+func hgRevset(rev string, nowalk bool) (string, bool, error) {
+	if strings.Contains(rev, "...") {
+		return "", false, fmt.Errorf("Revision '%s' is not supported with Mercurial, use a range such as 'A..B' instead", rev)
+	}
+
+	if strings.HasPrefix(rev, "^") {
+		set, err := hgRev(rev[1:])
+		return fmt.Sprintf("::%s", set), false, err
+	}
+
+	if parts := strings.SplitN(rev, "..", 2); len(parts) == 2 {
+		from, err := hgRev(parts[0])
+		if err != nil {
+			return "", false, err
+		}
+
+		to, err := hgRev(parts[1])
+		return fmt.Sprintf("only(%s, %s)", to, from), true, err
+	}
+
+	set, err := hgRev(rev)
+	if nowalk {
+		return set, true, err
+	}
+
+	return fmt.Sprintf("::%s", set), true, err
+}
+
+// Log lists commits that are selected by the query, newest first, the
+// query is translated into a revset. Dates are passed to hg as-is
+func (h *Hg) Log(q *Query) ([]*Commit, error) {
+	positive, negative := []string{}, []string{}
+	for _, rev := range q.Revs {
+		set, pos, err := hgRevset(rev, q.NoWalk)
+		if err != nil {
+			return nil, err
+		}
+
+		if pos {
+			positive = append(positive, set)
+		} else {
+			negative = append(negative, set)
+		}
+	}
+
+	if len(positive) == 0 {
+		if q.All {
+			positive = append(positive, "all()")
+		} else {
+			positive = append(positive, "::.")
+		}
+	}
+
+	filters := []string{"not " + hgNotesRevset()}
+	for _, neg := range negative {
+		filters = append(filters, "not "+neg)
+	}
+
+	if q.Since != "" {
+		filters = append(filters, "date("+strconv.Quote(">"+q.Since)+")")
+	}
+
+	if q.Until != "" {
+		filters = append(filters, "date("+strconv.Quote("<"+q.Until)+")")
+	}
+
+	if q.Author != "" {
+		filters = append(filters, "author("+strconv.Quote(q.Author)+")")
+	}
+
+	if q.Grep != "" {
+		filters = append(filters, "desc("+strconv.Quote(q.Grep)+")")
+	}
+
+	if len(q.Paths) > 0 {
+		files := []string{}
+		for _, p := range q.Paths {
+			files = append(files, "file("+strconv.Quote("path:"+p)+")")
+		}
+
+		filters = append(filters, "("+strings.Join(files, " or ")+")")
+	}
+
+	//plain revisions keep the order in which they were given
+	args := []string{"log", "--template", `{node}\x1f{date|hgdate}\x1f{author|email}\x1f{author|person}\x1f{desc|firstline}\x1f{desc}\x1f{branch}\x00`}
+	if q.NoWalk {
+		for _, set := range positive {
+			args = append(args, "-r", fmt.Sprintf("(%s) and %s", set, strings.Join(filters, " and ")))
+		}
+	} else {
+		args = append(args, "-r", fmt.Sprintf("sort((%s) and %s, -rev)", strings.Join(positive, " or "), strings.Join(filters, " and ")))
+	}
+
+	out, err := h.run(h.root, args...)
+	if err != nil {
+		return nil, errwrap.Wrapf("Failed to list commits: {{err}}", err)
+	}
+
+	commits := []*Commit{}
+	for _, record := range strings.Split(out, "\x00") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x1f", 7)
+		if len(fields) != 7 {
+			continue
+		}
+
+		ts, err := strconv.ParseFloat(strings.Fields(fields[1])[0], 64)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("Failed to parse date of commit '%s': {{err}}", fields[0]), err)
+		}
+
+		body := ""
+		if parts := strings.SplitN(fields[5], "\n", 2); len(parts) == 2 {
+			body = strings.TrimSpace(parts[1])
+		}
+
+		commits = append(commits, &Commit{
+			Hash:       fields[0],
+			Date:       time.Unix(int64(ts), 0),
+			Author:     fields[2],
+			AuthorName: fields[3],
+			Subject:    fields[4],
+			Body:       body,
+			Branch:     fields[6],
+		})
+	}
+
+	return commits, nil
+}
+
+// Pull fetches the notes branch of the remote, if both sides
+// changed time data the heads of the branch are merged
+func (h *Hg) Pull(remote string) error {
+	_, err := h.run(h.root, "pull", "-b", HgNotesBranch, remote)
+	if err != nil {
+		if strings.Contains(err.Error(), "unknown branch") {
+			return ErrNoRemoteTimeData
+		}
+
+		return errwrap.Wrapf(fmt.Sprintf("Failed to pull from remote '%s': {{err}}", remote), err)
+	}
+
+	heads, err := h.run(h.root, "heads", "--template", "{node}\n", HgNotesBranch)
+	if err != nil {
+		return errwrap.Wrapf("Failed to list heads of time data: {{err}}", err)
+	}
+
+	if len(strings.Split(heads, "\n")) > 1 {
+		return h.updateNotes("", nil)
+	}
+
+	return nil
+}
+
+// Push sends the notes branch to the remote
+func (h *Hg) Push(remote string, refs string) error {
+	if !h.hasNotesBranch(h.root) {
+		return ErrNoLocalTimeData
+	}
+
+	errbuff := bytes.NewBuffer(nil)
+	cmd := h.command(h.root, "push", "--new-branch", "-b", HgNotesBranch, remote)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = errbuff
+
+	//exit code 1 means there was nothing to push
+	err := cmd.Run()
+	if exiterr, ok := err.(*exec.ExitError); ok && strings.Contains(exiterr.Error(), "exit status 1") {
+		return nil
+	} else if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to push to remote '%s' (%s): {{err}}", remote, strings.TrimSpace(errbuff.String())), err)
+	}
+
+	return nil
+}
+
+func (h *Hg) Estimates() (map[string]*Estimate, error) {
+	content, _, err := h.readFile(h.root, estimatesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseEstimates(strings.NewReader(content))
+}
+
+func (h *Hg) SetEstimate(e *Estimate) error {
+	by, err := h.username()
+	if err != nil {
+		return err
+	}
+
+	e.By = by
+	e.At = time.Now()
+	return h.updateNotes(fmt.Sprintf("Estimate %s at %s", e.Key, e.Budget), func(dir string) error {
+		estimates := map[string]*Estimate{}
+		data, err := ioutil.ReadFile(filepath.Join(dir, estimatesFile))
+		if err == nil {
+			estimates, err = ParseEstimates(bytes.NewReader(data))
+			if err != nil {
+				return err
+			}
+		}
+
+		estimates[e.Key] = e
+		return ioutil.WriteFile(filepath.Join(dir, estimatesFile), []byte(FormatEstimates(estimates)), 0644)
+	})
+}
+
+func (h *Hg) CurrentBranch() (string, error) {
+	return h.run(h.root, "branch")
+}
+
+// BranchCommits lists the commits on the named branch that
+// can't be reached from the heads of any other branch
+func (h *Hg) BranchCommits(branch string) ([]string, error) {
+	b := strconv.Quote(branch)
+	revset := fmt.Sprintf("branch(%s) and not ::(head() and not branch(%s) and not %s)", b, b, hgNotesRevset())
+	out, err := h.run(h.root, "log", "-r", revset, "--template", "{node}\n")
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to list commits unique to branch '%s': {{err}}", branch), err)
+	}
+
+	if out == "" {
+		return []string{}, nil
+	}
+
+	return strings.Split(out, "\n"), nil
+}
+
+func isHgHook(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	for _, name := range []string{"precommit", "commit", "outgoing"} {
+		if strings.HasPrefix(trimmed, name+".timeglass") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Hook adds the Timeglass hooks to the [hooks] section of the
+// repository's hgrc, hooks that were added before are replaced
+// and all other configuration is kept as is. Mercurial runs
+// every hook of a section so existing hooks keep working
+func (h *Hg) Hook() ([]*Change, error) {
+	path := filepath.Join(h.root, ".hg", "hgrc")
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to read '%s': {{err}}", path), err)
+	}
+
+	lines := []string{}
+	inserted := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if isHgHook(line) {
+			continue
+		}
+
+		lines = append(lines, line)
+		if strings.TrimSpace(line) == "[hooks]" && !inserted {
+			lines = append(lines, hgHooks...)
+			inserted = true
+		}
+	}
+
+	if !inserted {
+		if len(lines) > 0 && lines[len(lines)-1] != "" {
+			lines = append(lines, "")
+		}
+
+		lines = append(lines, "[hooks]")
+		lines = append(lines, hgHooks...)
+		lines = append(lines, "")
+	}
+
+	err = ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to write hooks to '%s': {{err}}", path), err)
+	}
+
+	return []*Change{{path, "updated [hooks] section"}}, nil
+}
+
+// Unhook removes the Timeglass hooks from the hgrc, an
+// empty [hooks] section is left behind as it is harmless
+func (h *Hg) Unhook(dry bool) ([]*Change, error) {
+	path := filepath.Join(h.root, ".hg", "hgrc")
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []*Change{}, nil
+	} else if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to read '%s': {{err}}", path), err)
+	}
+
+	lines := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !isHgHook(line) {
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) == len(strings.Split(string(data), "\n")) {
+		return []*Change{}, nil
+	}
+
+	if dry {
+		return []*Change{{path, "remove hooks"}}, nil
+	}
+
+	err = ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to remove hooks from '%s': {{err}}", path), err)
+	}
+
+	return []*Change{{path, "removed hooks"}}, nil
+}
+
+// Cleanup removes the private clone that is used to write time
+// data, the notes branch can't be removed as it may have been
+// pushed already: it is kept even when 'data' is set
+func (h *Hg) Cleanup(dry, data bool) ([]*Change, error) {
+	changes := []*Change{}
+	work := filepath.Join(h.root, ".hg", "timeglass")
+	if _, err := os.Stat(work); err == nil {
+		changes = append(changes, &Change{work, "delete"})
+		if !dry {
+			err = os.RemoveAll(work)
+			if err != nil {
+				return nil, errwrap.Wrapf(fmt.Sprintf("Failed to remove '%s': {{err}}", work), err)
+			}
+		}
+	}
+
+	if data && h.hasNotesBranch(h.root) {
+		changes = append(changes, &Change{"branch " + HgNotesBranch, "kept, close it with 'hg commit --close-branch' on the branch"})
+	}
+
+	return changes, nil
+}
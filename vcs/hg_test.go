@@ -0,0 +1,189 @@
+package vcs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// creates a scratch mercurial repository with the given number
+// of commits, tests are skipped if hg is not installed
+func setupHgRepo(t testing.TB, n int) (*Hg, []string) {
+	if _, err := exec.LookPath("hg"); err != nil {
+		t.Skip("hg is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "glass_hg")
+	assert.NoError(t, err)
+
+	run := func(args ...string) string {
+		cmd := exec.Command("hg", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "HGPLAIN=1", "HGUSER=Glass <glass@example.com>")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("hg %s failed: %s, %s", args, err, out)
+		}
+
+		return strings.TrimSpace(string(out))
+	}
+
+	run("init")
+	err = ioutil.WriteFile(filepath.Join(dir, ".hg", "hgrc"), []byte("[ui]\nusername = Glass <glass@example.com>\n"), 0644)
+	assert.NoError(t, err)
+
+	commits := []string{}
+	for i := 0; i < n; i++ {
+		err = ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte(fmt.Sprintf("%d", i)), 0644)
+		assert.NoError(t, err)
+
+		run("commit", "-A", "-m", fmt.Sprintf("commit %d", i))
+		commits = append(commits, run("log", "-r", ".", "--template", "{node}"))
+	}
+
+	h := NewHg(dir)
+	assert.True(t, h.IsAvailable())
+	return h, commits
+}
+
+func TestHgPersistShow(t *testing.T) {
+	h, commits := setupHgRepo(t, 3)
+
+	_, err := h.Show(commits[0])
+	assert.Equal(t, ErrNoCommitTimeData, err)
+
+	assert.NoError(t, h.Persist(commits[0], PunchAdd, &TimeEntry{Time: 5 * time.Minute}, ""))
+	assert.NoError(t, h.Persist(commits[0], PunchAdd, &TimeEntry{Time: 2 * time.Minute}, ""))
+	assert.NoError(t, h.Persist("HEAD", PunchAdd, &TimeEntry{Time: time.Hour}, ""))
+
+	data, err := h.Show(commits[0])
+	assert.NoError(t, err)
+	assert.Equal(t, 7*time.Minute, data.Total())
+
+	all, err := h.ShowAll(commits)
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+	assert.Equal(t, time.Hour, all[commits[2]].Total())
+
+	//the notes branch is never part of the log
+	log, err := h.Log(&Query{All: true})
+	assert.NoError(t, err)
+	assert.Len(t, log, 3)
+	assert.Equal(t, commits[2], log[0].Hash)
+	assert.Equal(t, "glass@example.com", log[0].Author)
+}
+
+func TestHgPushPull(t *testing.T) {
+	a, commits := setupHgRepo(t, 1)
+	b, _ := setupHgRepo(t, 0)
+
+	assert.Equal(t, ErrNoLocalTimeData, a.Push(b.Root(), ""))
+	assert.NoError(t, a.Persist(commits[0], PunchAdd, &TimeEntry{Time: 10 * time.Minute}, ""))
+	assert.NoError(t, a.SetEstimate(&Estimate{Key: BranchKey("default"), Budget: time.Hour}))
+	assert.NoError(t, a.Push(b.Root(), ""))
+
+	c, _ := setupHgRepo(t, 0)
+	assert.Equal(t, ErrNoRemoteTimeData, b.Pull(c.Root()))
+	assert.NoError(t, c.Pull(b.Root()))
+
+	data, err := c.Show(commits[0])
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, data.Total())
+
+	estimates, err := c.Estimates()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, estimates[BranchKey("default")].Budget)
+}
+
+func TestHgHook(t *testing.T) {
+	h, _ := setupHgRepo(t, 0)
+
+	_, err := h.Hook()
+	assert.NoError(t, err)
+	_, err = h.Hook()
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadFile(filepath.Join(h.Root(), ".hg", "hgrc"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "username = Glass <glass@example.com>")
+	assert.Equal(t, 1, strings.Count(string(data), "[hooks]"))
+	assert.Equal(t, 1, strings.Count(string(data), "commit.timeglass ="))
+
+	_, err = h.Unhook(false)
+	assert.NoError(t, err)
+
+	data, err = ioutil.ReadFile(filepath.Join(h.Root(), ".hg", "hgrc"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "username = Glass <glass@example.com>")
+	assert.NotContains(t, string(data), "timeglass")
+}
+
+func TestHgRev(t *testing.T) {
+	for rev, expected := range map[string]string{
+		"HEAD":       `"."`,
+		"":           `"."`,
+		"HEAD~1":     `"."~1`,
+		"HEAD^^":     `"."^1^1`,
+		"3f2a9c0b1d": `"3f2a9c0b1d"`,
+		"v0.5.0^2":   `"v0.5.0"^2`,
+		"my branch":  `"my branch"`,
+		`say"hi"`:    `"say\"hi\""`,
+	} {
+		set, err := hgRev(rev)
+		assert.NoError(t, err, rev)
+		assert.Equal(t, expected, set, rev)
+	}
+
+	//unsupported revisions are refused before hg is asked
+	h := NewHg(os.TempDir())
+	for _, rev := range []string{"HEAD@{1}", ":/fix", "HEAD^3"} {
+		_, err := h.node(rev)
+		if assert.Error(t, err, rev) {
+			assert.Contains(t, err.Error(), "is not supported with Mercurial", rev)
+		}
+	}
+}
+
+func TestHgRevset(t *testing.T) {
+	for _, c := range []struct {
+		rev      string
+		nowalk   bool
+		expected string
+		positive bool
+		err      string
+	}{
+		{"HEAD", false, `::"."`, true, ""},
+		{"HEAD", true, `"."`, true, ""},
+		{"HEAD~2", true, `"."~2`, true, ""},
+		{"master^", false, `::"master"^1`, true, ""},
+		{"v0.5.0^2~", true, `"v0.5.0"^2~1`, true, ""},
+		{"^master~1", false, `::"master"~1`, false, ""},
+		{"v0.5.0..HEAD~1", false, `only("."~1, "v0.5.0")`, true, ""},
+		{"..HEAD", false, `only(".", ".")`, true, ""},
+		{"A...B", false, "", false, "use a range such as 'A..B'"},
+		{"HEAD@{2}", false, "", false, "is not supported with Mercurial"},
+		{":/fix", false, "", false, "is not supported with Mercurial"},
+		{"HEAD^3", false, "", false, "at most two parents"},
+		{"HEAD^!", false, "", false, "is not supported with Mercurial"},
+	} {
+		set, pos, err := hgRevset(c.rev, c.nowalk)
+		if c.err != "" {
+			if assert.Error(t, err, c.rev) {
+				assert.Contains(t, err.Error(), c.err, c.rev)
+			}
+
+			continue
+		}
+
+		assert.NoError(t, err, c.rev)
+		assert.Equal(t, c.expected, set, c.rev)
+		assert.Equal(t, c.positive, pos, c.rev)
+	}
+}
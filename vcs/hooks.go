@@ -0,0 +1,244 @@
+package vcs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+)
+
+// the part of a hook that belongs to Timeglass is always
+// placed between these lines such that it can be found,
+// replaced and removed without touching anything else
+var HookBlockStart = "# >>> timeglass >>>"
+var HookBlockEnd = "# <<< timeglass <<<"
+
+// hooks that can't hold a block are moved aside and
+// run by a wrapper before Timeglass does its work
+var HookOriginalSuffix = ".timeglass-original"
+
+var hookWrapperTmpl = template.Must(template.New("wrapper").Parse(`#!/bin/sh
+{{.Start}}
+# the original hook was moved to {{.Name}}{{.Suffix}}, it runs first
+# and is put back when Timeglass is removed using 'glass deinit'
+TIMEGLASS_ORIGINAL="$(dirname "$0")/{{.Name}}{{.Suffix}}"
+{{if .Stdin}}TIMEGLASS_STDIN="$(mktemp)"
+cat > "$TIMEGLASS_STDIN"
+if [ -x "$TIMEGLASS_ORIGINAL" ]; then
+	"$TIMEGLASS_ORIGINAL" "$@" < "$TIMEGLASS_STDIN" || { STATUS=$?; rm -f "$TIMEGLASS_STDIN"; exit $STATUS; }
+fi
+
+{
+{{.Block}}
+} < "$TIMEGLASS_STDIN"
+rm -f "$TIMEGLASS_STDIN"
+{{else}}if [ -x "$TIMEGLASS_ORIGINAL" ]; then
+	"$TIMEGLASS_ORIGINAL" "$@" || exit $?
+fi
+
+{{.Block}}
+{{end}}{{.End}}
+`))
+
+// finds the Timeglass block in the lines of a hook, it returns -1 for
+// both if there is none or the start and end line (inclusive)
+func findHookBlock(lines []string) (int, int) {
+	start := -1
+	for i, l := range lines {
+		switch strings.TrimSpace(l) {
+		case HookBlockStart:
+			start = i
+		case HookBlockEnd:
+			if start > -1 {
+				return start, i
+			}
+		}
+	}
+
+	return -1, -1
+}
+
+// the hooks exactly as earlier versions of Timeglass wrote them
+var legacyHooks = []string{`#!/bin/sh
+# only add time to template and message sources
+# @see http://git-scm.com/docs/githooks#_prepare_commit_msg
+case "$2" in
+message|template) 
+	# -m method
+	printf "$(cat $1)$(glass -s status --commit-template)" > "$1" ;;
+"")
+	# interactive method
+	printf "$(glass -s status --commit-template)\n$(cat $1)" > "$1" ;;	
+esac
+`, `#!/bin/sh
+#persist (punch) to newly created commit and reset the timer
+glass -s status -t "{{.}}" | glass punch
+glass reset
+`, `#!/bin/sh
+#push time data
+glass push $1
+`}
+
+// hooks that were written by earlier versions of Timeglass and weren't
+// changed since can simply be overwritten, hooks that hold anything else
+// (e.g: commands that were pasted in) are treated as any other hook
+func isLegacyHook(content string) bool {
+	for _, legacy := range legacyHooks {
+		if strings.Join(strings.Fields(content), "") == strings.Join(strings.Fields(legacy), "") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isHookWrapper(path string) bool {
+	_, err := os.Stat(path + HookOriginalSuffix)
+	return err == nil
+}
+
+func writeHook(path, content string) error {
+	err := ioutil.WriteFile(path, []byte(content), 0755)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to write hook '%s': {{err}}", path), err)
+	}
+
+	//the mode is only used when the file is created
+	err = os.Chmod(path, 0755)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("Failed to make hook '%s' executable: {{err}}", path), err)
+	}
+
+	return nil
+}
+
+// InstallHook adds the block of shell commands to the hook at the given
+// path without destroying what is already there: a missing hook is
+// created, an existing Timeglass block is replaced and other hooks are
+// either moved aside and wrapped or, when 'inline' is set (e.g for the
+// scripts of hook managers that are kept in the repository), get the
+// block appended. Hooks that read from stdin should set 'stdin' such
+// that a wrapper provides the input to both the original and the block
+func InstallHook(path, block string, stdin, inline bool) (*Change, error) {
+	marked := HookBlockStart + "\n" + strings.TrimSpace(block) + "\n" + HookBlockEnd
+	wrap := func() (string, error) {
+		buff := bytes.NewBuffer(nil)
+		err := hookWrapperTmpl.Execute(buff, struct {
+			Name, Suffix, Block, Start, End string
+			Stdin                           bool
+		}{filepath.Base(path), HookOriginalSuffix, strings.TrimSpace(block), HookBlockStart, HookBlockEnd, stdin})
+		return buff.String(), err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Change{path, "created"}, writeHook(path, "#!/bin/sh\n"+marked+"\n")
+	} else if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to read hook '%s': {{err}}", path), err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	start, end := findHookBlock(lines)
+	switch {
+	case start > -1 && isHookWrapper(path):
+		content, err := wrap()
+		if err != nil {
+			return nil, errwrap.Wrapf("Failed to render hook wrapper: {{err}}", err)
+		}
+
+		return &Change{path, "updated wrapper"}, writeHook(path, content)
+	case start > -1:
+		lines = append(lines[:start], append([]string{marked}, lines[end+1:]...)...)
+		return &Change{path, "updated block"}, writeHook(path, strings.Join(lines, "\n"))
+	case isLegacyHook(string(data)):
+		return &Change{path, "replaced hook of an earlier version"}, writeHook(path, "#!/bin/sh\n"+marked+"\n")
+	case inline:
+		content := strings.TrimRight(string(data), "\n") + "\n\n" + marked + "\n"
+		return &Change{path, "appended block"}, writeHook(path, content)
+	}
+
+	err = os.Rename(path, path+HookOriginalSuffix)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to move existing hook '%s' aside: {{err}}", path), err)
+	}
+
+	content, err := wrap()
+	if err != nil {
+		return nil, errwrap.Wrapf("Failed to render hook wrapper: {{err}}", err)
+	}
+
+	return &Change{path, "wrapped existing hook"}, writeHook(path, content)
+}
+
+// UninstallHook removes whatever InstallHook added to the hook at the
+// given path: wrapped hooks are put back, blocks are removed and hooks
+// that are left without any commands are deleted. Nothing is changed
+// when 'dry' is set, it returns nil if the hook has no Timeglass parts
+func UninstallHook(path string, dry bool) (*Change, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to read hook '%s': {{err}}", path), err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	start, end := findHookBlock(lines)
+	if start < 0 {
+		if !isLegacyHook(string(data)) {
+			return nil, nil
+		}
+
+		//a hook of an earlier version is Timeglass's entirely
+		lines = []string{}
+	}
+
+	if start > -1 && isHookWrapper(path) {
+		if dry {
+			return &Change{path, "restore original hook"}, nil
+		}
+
+		err = os.Rename(path+HookOriginalSuffix, path)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("Failed to restore original hook '%s': {{err}}", path), err)
+		}
+
+		return &Change{path, "restored original hook"}, nil
+	}
+
+	if start > -1 {
+		lines = append(lines[:start], lines[end+1:]...)
+	}
+
+	empty := true
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l != "" && !strings.HasPrefix(l, "#") {
+			empty = false
+		}
+	}
+
+	if empty {
+		if dry {
+			return &Change{path, "remove hook"}, nil
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("Failed to remove hook '%s': {{err}}", path), err)
+		}
+
+		return &Change{path, "removed hook"}, nil
+	}
+
+	if dry {
+		return &Change{path, "remove block"}, nil
+	}
+
+	content := strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
+	return &Change{path, "removed block"}, writeHook(path, content)
+}
@@ -0,0 +1,284 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallHookCreatesAndRemoves(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_hooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "post-commit")
+	change, err := InstallHook(path, "echo timeglass", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "created", change.Action)
+
+	//installing again replaces the block
+	change, err = InstallHook(path, "echo timeglass again", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "updated block", change.Action)
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), HookBlockStart))
+	assert.Contains(t, string(data), "echo timeglass again")
+
+	change, err = UninstallHook(path, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "removed hook", change.Action)
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestInstallHookWrapsExisting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_hooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+	path := filepath.Join(dir, "pre-push")
+	original := "#!/bin/sh\necho \"lint $1 $(cat)\" >> " + out + "\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(original), 0755))
+
+	change, err := InstallHook(path, "echo \"timeglass $1 $(cat)\" >> "+out, true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "wrapped existing hook", change.Action)
+
+	//the original runs first and both get the input
+	cmd := exec.Command(path, "origin")
+	cmd.Stdin = strings.NewReader("refs/heads/master")
+	assert.NoError(t, cmd.Run())
+
+	data, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "lint origin refs/heads/master\ntimeglass origin refs/heads/master\n", string(data))
+
+	change, err = InstallHook(path, "echo timeglass", true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "updated wrapper", change.Action)
+
+	change, err = UninstallHook(path, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "restore original hook", change.Action)
+
+	_, err = UninstallHook(path, false)
+	assert.NoError(t, err)
+
+	data, err = ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, original, string(data))
+
+	_, err = os.Stat(path + HookOriginalSuffix)
+	assert.True(t, os.IsNotExist(err))
+
+	//whitespace doesn't matter, e.g: line endings
+	path = filepath.Join(dir, "post-commit")
+	legacy := "#!/bin/sh\r\n#persist (punch) to newly created commit and reset the timer\r\nglass -s status -t \"{{.}}\" | glass punch\r\nglass reset\r\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(legacy), 0755))
+
+	change, err = InstallHook(path, PostCommitHook, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "replaced hook of an earlier version", change.Action)
+}
+
+func TestInstallHookFailingOriginal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_hooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "post-commit")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("#!/bin/sh\nexit 3\n"), 0755))
+
+	_, err = InstallHook(path, "touch "+filepath.Join(dir, "ran"), false, false)
+	assert.NoError(t, err)
+
+	err = exec.Command(path).Run()
+	assert.Error(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "ran"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestInstallHookInline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_hooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "post-commit")
+	original := "npx lint-staged\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(original), 0644))
+
+	change, err := InstallHook(path, "echo timeglass", false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "appended block", change.Action)
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), original))
+
+	change, err = UninstallHook(path, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "removed block", change.Action)
+
+	data, err = ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, original, string(data))
+}
+
+func TestInstallHookReplacesLegacy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_hooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "pre-push")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n#push time data\nglass push $1\n"), 0755))
+
+	change, err := InstallHook(path, PrePushHook, true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "replaced hook of an earlier version", change.Action)
+
+	_, err = os.Stat(path + HookOriginalSuffix)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestInstallHookKeepsMixedLegacy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_hooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	//the commands of an earlier version were pasted into another hook
+	path := filepath.Join(dir, "post-commit")
+	original := "#!/bin/sh\nnpx lint-staged\nglass -s status -t \"{{.}}\" | glass punch\nglass reset\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(original), 0755))
+
+	change, err := InstallHook(path, PostCommitHook, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "wrapped existing hook", change.Action)
+
+	data, err := ioutil.ReadFile(path + HookOriginalSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, original, string(data))
+
+	change, err = UninstallHook(path, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "restored original hook", change.Action)
+
+	data, err = ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, original, string(data))
+
+	//as is the hook is not touched at all
+	change, err = UninstallHook(path, false)
+	assert.NoError(t, err)
+	assert.Nil(t, change)
+}
+
+func TestGitHookRespectsHooksPath(t *testing.T) {
+	g, _ := setupGitRepo(t, 1)
+	defer os.RemoveAll(g.Root())
+
+	cmd := exec.Command("git", "config", "core.hooksPath", ".githooks")
+	cmd.Dir = g.Root()
+	assert.NoError(t, cmd.Run())
+
+	changes, err := g.Hook()
+	assert.NoError(t, err)
+	assert.Len(t, changes, 3)
+
+	_, err = os.Stat(filepath.Join(g.Root(), ".githooks", "post-commit"))
+	assert.NoError(t, err)
+
+	changes, err = g.Unhook(false)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 3)
+
+	_, err = os.Stat(filepath.Join(g.Root(), ".githooks", "post-commit"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGitHookFromWorktree(t *testing.T) {
+	g, _ := setupGitRepo(t, 1)
+	defer os.RemoveAll(g.Root())
+
+	wt := filepath.Join(g.Root(), "wt")
+	cmd := exec.Command("git", "worktree", "add", "-q", "-b", "other", wt)
+	cmd.Dir = g.Root()
+	assert.NoError(t, cmd.Run())
+
+	w := NewGit(wt)
+	assert.True(t, w.IsAvailable())
+	assert.Equal(t, wt, w.Root())
+
+	_, err := w.Hook()
+	assert.NoError(t, err)
+
+	//hooks are shared by all worktrees
+	_, err = os.Stat(filepath.Join(g.Root(), ".git", "hooks", "post-commit"))
+	assert.NoError(t, err)
+
+	dirs, err := g.Worktrees()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{wt}, dirs)
+}
+
+func TestGitSubmodules(t *testing.T) {
+	g, _ := setupGitRepo(t, 1)
+	defer os.RemoveAll(g.Root())
+	sub, _ := setupGitRepo(t, 1)
+	defer os.RemoveAll(sub.Root())
+
+	cmd := exec.Command("git", "-c", "protocol.file.allow=always", "submodule", "--quiet", "add", sub.Root(), "lib")
+	cmd.Dir = g.Root()
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+
+	dirs, err := g.Submodules()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(g.Root(), "lib")}, dirs)
+
+	s := NewGit(filepath.Join(g.Root(), "lib"))
+	assert.True(t, s.IsAvailable())
+
+	super, err := s.Superproject()
+	assert.NoError(t, err)
+	assert.Equal(t, g.Root(), super)
+
+	//the hooks of a submodule are kept in the superproject's git dir
+	_, err = s.Hook()
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(g.Root(), ".git", "modules", "lib", "hooks", "post-commit"))
+	assert.NoError(t, err)
+}
+
+func TestPostCommitHookKeepsUnpunchedTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_hooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	//a glass that fails to punch, e.g: because a timer is held
+	calls := filepath.Join(dir, "calls")
+	fake := "#!/bin/sh\necho \"$1\" >> " + calls + "\n[ \"$1\" != \"punch\" ]\n"
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "glass"), []byte(fake), 0755))
+
+	path := filepath.Join(dir, "post-commit")
+	_, err = InstallHook(path, PostCommitHook, false, false)
+	assert.NoError(t, err)
+
+	cmd := exec.Command(path)
+	cmd.Env = append(os.Environ(), "PATH="+dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	cmd.Run()
+
+	data, err := ioutil.ReadFile(calls)
+	assert.NoError(t, err)
+	assert.Equal(t, "punch\n", string(data))
+}
@@ -0,0 +1,419 @@
+package vcs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+)
+
+// the version of the note format that is written
+// by this version of Timeglass, notes without a
+// version line are considered to be version 1
+const NoteVersion = 2
+
+const (
+	VERSION_PREFIX = "version="
+	TOTAL_PREFIX   = "total="
+	ENTRY_PREFIX   = "entry "
+	AUDIT_PREFIX   = "audit "
+)
+
+// PunchMode determines how punched time
+// is combined with an existing entry
+type PunchMode string
+
+var (
+	PunchSet      = PunchMode("set")
+	PunchAdd      = PunchMode("add")
+	PunchSubtract = PunchMode("subtract")
+)
+
+// A single contribution to the time spent on a commit, the
+// project is the path of the sub-project the time was measured
+// for relative to the root, it is empty for the root itself
+type TimeEntry struct {
+	Author  string
+	Project string
+	Time    time.Duration
+	MBU     time.Duration
+	Machine string
+	Start   time.Time
+	End     time.Time
+
+	//time measured outside of working hours, it
+	//is not included in the time of the entry
+	OffHours time.Duration
+}
+
+// A record of a single change to the time of a note
+type Audit struct {
+	At      time.Time
+	By      string
+	Mode    PunchMode
+	Author  string
+	Project string
+	Time    time.Duration
+	Message string
+}
+
+// Note holds all time data that is attached to a single
+// commit, it reads both the legacy (total only) and the
+// versioned format but always writes the latter
+type Note struct {
+	version int
+	total   time.Duration
+	entries []*TimeEntry
+	audits  []*Audit
+}
+
+func NewNote() *Note {
+	return &Note{version: NoteVersion}
+}
+
+func (n *Note) Version() int          { return n.version }
+func (n *Note) Entries() []*TimeEntry { return n.entries }
+func (n *Note) Audits() []*Audit      { return n.audits }
+
+func (n *Note) Total() time.Duration {
+	if len(n.entries) == 0 {
+		return n.total
+	}
+
+	var total time.Duration
+	for _, e := range n.entries {
+		total += e.Time
+	}
+
+	return total
+}
+
+// OffHours returns the time that all authors spent outside of their
+// working hours, it is reported apart from the total
+func (n *Note) OffHours() time.Duration {
+	var total time.Duration
+	for _, e := range n.entries {
+		total += e.OffHours
+	}
+
+	return total
+}
+
+// Entry returns the entry of the given author and project or
+// nil if the author didn't contribute to it in this commit
+func (n *Note) Entry(author, project string) *TimeEntry {
+	for _, e := range n.entries {
+		if e.Author == author && e.Project == project {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// Set replaces the entry of the same author and project
+// or adds it when there is no such entry yet
+func (n *Note) Set(entry *TimeEntry) {
+	for i, e := range n.entries {
+		if e.Author == entry.Author && e.Project == entry.Project {
+			n.entries[i] = entry
+			return
+		}
+	}
+
+	n.entries = append(n.entries, entry)
+}
+
+// Apply changes the entry of the author using the given mode and
+// appends an audit line that records who made the change and why
+func (n *Note) Apply(mode PunchMode, entry *TimeEntry, by, message string) error {
+	existing := n.Entry(entry.Author, entry.Project)
+	switch mode {
+	case PunchSet:
+		n.Set(entry)
+	case PunchAdd:
+		if existing == nil {
+			n.Set(entry)
+			break
+		}
+
+		existing.Time += entry.Time
+		existing.OffHours += entry.OffHours
+		if existing.MBU == 0 {
+			existing.MBU = entry.MBU
+		}
+
+		if existing.Machine == "" {
+			existing.Machine = entry.Machine
+		}
+
+		if !entry.Start.IsZero() && (existing.Start.IsZero() || entry.Start.Before(existing.Start)) {
+			existing.Start = entry.Start
+		}
+
+		if entry.End.After(existing.End) {
+			existing.End = entry.End
+		}
+	case PunchSubtract:
+		if existing == nil {
+			return fmt.Errorf("Author '%s' has no time recorded to subtract from", entry.Author)
+		}
+
+		if entry.Time > existing.Time {
+			return fmt.Errorf("Cannot subtract %s from the %s recorded for author '%s'", entry.Time, existing.Time, entry.Author)
+		}
+
+		//time outside of working hours can't outlast the time itself
+		existing.Time -= entry.Time
+		if existing.OffHours > existing.Time {
+			existing.OffHours = existing.Time
+		}
+	default:
+		return fmt.Errorf("Unknown punch mode '%s'", mode)
+	}
+
+	n.audits = append(n.audits, &Audit{
+		At:      time.Now(),
+		By:      by,
+		Mode:    mode,
+		Author:  entry.Author,
+		Project: entry.Project,
+		Time:    entry.Time,
+		Message: message,
+	})
+
+	return nil
+}
+
+// Upgrade converts a legacy note into the current format, the
+// legacy total is attributed to the given author
+func (n *Note) Upgrade(author string) bool {
+	if n.version >= NoteVersion {
+		return false
+	}
+
+	if len(n.entries) == 0 && n.total > 0 {
+		n.entries = append(n.entries, &TimeEntry{Author: author, Time: n.total})
+	}
+
+	n.version = NoteVersion
+	return true
+}
+
+func (n *Note) String() string {
+	buff := bytes.NewBuffer(nil)
+	fmt.Fprintf(buff, "%s%d\n", VERSION_PREFIX, NoteVersion)
+	fmt.Fprintf(buff, "%s%s\n", TOTAL_PREFIX, n.Total())
+	for _, e := range n.entries {
+		fmt.Fprintf(buff, "%s%s\n", ENTRY_PREFIX, e)
+	}
+
+	for _, a := range n.audits {
+		fmt.Fprintf(buff, "%s%s\n", AUDIT_PREFIX, a)
+	}
+
+	return buff.String()
+}
+
+func (e *TimeEntry) String() string {
+	fields := []string{
+		"author=" + url.PathEscape(e.Author),
+		"time=" + e.Time.String(),
+	}
+
+	if e.Project != "" {
+		fields = append(fields, "project="+url.PathEscape(e.Project))
+	}
+
+	if e.OffHours != 0 {
+		fields = append(fields, "off_hours="+e.OffHours.String())
+	}
+
+	if e.MBU != 0 {
+		fields = append(fields, "mbu="+e.MBU.String())
+	}
+
+	if e.Machine != "" {
+		fields = append(fields, "machine="+url.PathEscape(e.Machine))
+	}
+
+	if !e.Start.IsZero() {
+		fields = append(fields, "start="+e.Start.UTC().Format(time.RFC3339))
+	}
+
+	if !e.End.IsZero() {
+		fields = append(fields, "end="+e.End.UTC().Format(time.RFC3339))
+	}
+
+	return strings.Join(fields, " ")
+}
+
+func (a *Audit) String() string {
+	fields := []string{
+		"at=" + a.At.UTC().Format(time.RFC3339),
+		"by=" + url.PathEscape(a.By),
+		"mode=" + string(a.Mode),
+		"author=" + url.PathEscape(a.Author),
+		"time=" + a.Time.String(),
+	}
+
+	if a.Project != "" {
+		fields = append(fields, "project="+url.PathEscape(a.Project))
+	}
+
+	if a.Message != "" {
+		fields = append(fields, "message="+url.PathEscape(a.Message))
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// ParseNote reads time data in any of the known note formats,
+// lines that are not recognized are skipped such that newer
+// notes can still be read by older versions
+func ParseNote(r io.Reader) (*Note, error) {
+	n := &Note{version: 1}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case strings.HasPrefix(line, VERSION_PREFIX):
+			v, err := strconv.Atoi(line[len(VERSION_PREFIX):])
+			if err != nil {
+				return n, errwrap.Wrapf(fmt.Sprintf("Failed to parse version from line '%s': {{err}}", line), err)
+			}
+
+			n.version = v
+		case strings.HasPrefix(line, TOTAL_PREFIX):
+			t, err := time.ParseDuration(line[len(TOTAL_PREFIX):])
+			if err != nil {
+				return n, errwrap.Wrapf(fmt.Sprintf("Failed to parse time from line '%s': {{err}}", line), err)
+			}
+
+			n.total = t
+		case strings.HasPrefix(line, ENTRY_PREFIX):
+			e, err := parseEntry(line[len(ENTRY_PREFIX):])
+			if err != nil {
+				return n, errwrap.Wrapf(fmt.Sprintf("Failed to parse entry from line '%s': {{err}}", line), err)
+			}
+
+			n.entries = append(n.entries, e)
+		case strings.HasPrefix(line, AUDIT_PREFIX):
+			a, err := parseAudit(line[len(AUDIT_PREFIX):])
+			if err != nil {
+				return n, errwrap.Wrapf(fmt.Sprintf("Failed to parse audit from line '%s': {{err}}", line), err)
+			}
+
+			n.audits = append(n.audits, a)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return n, errwrap.Wrapf("Failed to scan note: {{err}}", err)
+	}
+
+	return n, nil
+}
+
+func parseEntry(line string) (*TimeEntry, error) {
+	e := &TimeEntry{}
+	for _, field := range strings.Fields(line) {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		var err error
+		switch parts[0] {
+		case "author":
+			e.Author, err = url.PathUnescape(parts[1])
+		case "project":
+			e.Project, err = url.PathUnescape(parts[1])
+		case "time":
+			e.Time, err = time.ParseDuration(parts[1])
+		case "off_hours":
+			e.OffHours, err = time.ParseDuration(parts[1])
+		case "mbu":
+			e.MBU, err = time.ParseDuration(parts[1])
+		case "machine":
+			e.Machine, err = url.PathUnescape(parts[1])
+		case "start":
+			e.Start, err = time.Parse(time.RFC3339, parts[1])
+		case "end":
+			e.End, err = time.Parse(time.RFC3339, parts[1])
+		}
+
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("Invalid value for field '%s': {{err}}", parts[0]), err)
+		}
+	}
+
+	return e, nil
+}
+
+func parseAudit(line string) (*Audit, error) {
+	a := &Audit{}
+	for _, field := range strings.Fields(line) {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		var err error
+		switch parts[0] {
+		case "at":
+			a.At, err = time.Parse(time.RFC3339, parts[1])
+		case "by":
+			a.By, err = url.PathUnescape(parts[1])
+		case "mode":
+			a.Mode = PunchMode(parts[1])
+		case "author":
+			a.Author, err = url.PathUnescape(parts[1])
+		case "project":
+			a.Project, err = url.PathUnescape(parts[1])
+		case "time":
+			a.Time, err = time.ParseDuration(parts[1])
+		case "message":
+			a.Message, err = url.PathUnescape(parts[1])
+		}
+
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("Invalid value for field '%s': {{err}}", parts[0]), err)
+		}
+	}
+
+	return a, nil
+}
+
+// MachineID returns a short identifier for the current machine, it
+// is derived from the os machine id (or hostname) such that the
+// original value isn't shared when notes are pushed
+func MachineID() string {
+	var raw string
+	for _, p := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		data, err := ioutil.ReadFile(p)
+		if err == nil && len(bytes.TrimSpace(data)) > 0 {
+			raw = string(bytes.TrimSpace(data))
+			break
+		}
+	}
+
+	if raw == "" {
+		raw, _ = os.Hostname()
+	}
+
+	if raw == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%x", sha1.Sum([]byte(raw)))[:12]
+}
@@ -0,0 +1,187 @@
+package vcs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoteRoundTrip(t *testing.T) {
+	start := time.Date(2015, 6, 1, 9, 0, 0, 0, time.UTC)
+	for _, entries := range [][]*TimeEntry{
+		{},
+		{{Author: "alice@example.com", Time: 90 * time.Minute}},
+		{
+			{Author: "alice@example.com", Time: time.Hour, MBU: time.Minute, Machine: "3f2a9c0b1d4e", Start: start, End: start.Add(time.Hour)},
+			{Author: "bob@example.com", Project: "services/api", Time: 20 * time.Minute, OffHours: 5 * time.Minute},
+		},
+		{{Author: "Alice Smith <alice+time@example.com>", Project: "my project/100%", Time: time.Second, Machine: "a b=c"}},
+	} {
+		n := NewNote()
+		for _, e := range entries {
+			n.Set(e)
+		}
+
+		parsed, err := ParseNote(strings.NewReader(n.String()))
+		assert.NoError(t, err)
+		assert.Equal(t, NoteVersion, parsed.Version())
+		assert.Equal(t, n.Total(), parsed.Total())
+		assert.Equal(t, n.OffHours(), parsed.OffHours())
+		assert.Equal(t, n.String(), parsed.String())
+		if assert.Len(t, parsed.Entries(), len(entries)) {
+			for i, e := range entries {
+				assert.Equal(t, e.Author, parsed.Entries()[i].Author)
+				assert.Equal(t, e.Project, parsed.Entries()[i].Project)
+				assert.Equal(t, e.Machine, parsed.Entries()[i].Machine)
+				assert.True(t, e.Start.Equal(parsed.Entries()[i].Start))
+			}
+		}
+	}
+}
+
+func TestParseNote(t *testing.T) {
+	for data, expected := range map[string]struct {
+		version int
+		total   time.Duration
+		entries int
+		audits  int
+		err     string
+	}{
+		//version 1 only holds a total
+		"total=1h20m":   {1, 80 * time.Minute, 0, 0, ""},
+		"total=1h20m\n": {1, 80 * time.Minute, 0, 0, ""},
+		"":              {1, 0, 0, 0, ""},
+		"version=2\ntotal=5m\nentry author=a%40b time=5m":                                                                         {2, 5 * time.Minute, 1, 0, ""},
+		"version=2\ntotal=99h\nentry author=a time=5m\nentry author=b time=10m":                                                   {2, 15 * time.Minute, 2, 0, ""},
+		"version=2\nentry author=a time=5m\naudit at=2015-06-01T09:00:00Z by=a mode=add author=a time=5m message=forgot%20review": {2, 5 * time.Minute, 1, 1, ""},
+
+		//unknown lines and fields are skipped for newer versions
+		"version=3\nsomething new\nentry author=a time=5m color=blue": {3, 5 * time.Minute, 1, 0, ""},
+
+		"total=banana": {0, 0, 0, 0, "Failed to parse time from line 'total=banana'"},
+		"version=two":  {0, 0, 0, 0, "Failed to parse version"},
+		"version=2\nentry author=a time=5 minutes":    {0, 0, 0, 0, "Invalid value for field 'time'"},
+		"version=2\nentry author=%zz time=5m":         {0, 0, 0, 0, "Invalid value for field 'author'"},
+		"version=2\naudit at=yesterday by=a mode=set": {0, 0, 0, 0, "Invalid value for field 'at'"},
+	} {
+		n, err := ParseNote(strings.NewReader(data))
+		if expected.err != "" {
+			if assert.Error(t, err, data) {
+				assert.Contains(t, err.Error(), expected.err, data)
+			}
+
+			continue
+		}
+
+		assert.NoError(t, err, data)
+		assert.Equal(t, expected.version, n.Version(), data)
+		assert.Equal(t, expected.total, n.Total(), data)
+		assert.Len(t, n.Entries(), expected.entries, data)
+		assert.Len(t, n.Audits(), expected.audits, data)
+	}
+}
+
+func TestNoteEscaping(t *testing.T) {
+	n := NewNote()
+	assert.NoError(t, n.Apply(PunchSet, &TimeEntry{Author: "a b@example.com", Project: "sub project", Time: time.Minute}, "c=d@example.com", "pair programming\nwith bob"))
+
+	//every entry and audit stays on a single line
+	lines := strings.Split(strings.TrimSpace(n.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.Contains(t, lines[2], "author=a%20b@example.com")
+	assert.Contains(t, lines[2], "project=sub%20project")
+
+	parsed, err := ParseNote(strings.NewReader(n.String()))
+	assert.NoError(t, err)
+	if assert.Len(t, parsed.Audits(), 1) {
+		assert.Equal(t, "c=d@example.com", parsed.Audits()[0].By)
+		assert.Equal(t, "pair programming\nwith bob", parsed.Audits()[0].Message)
+		assert.Equal(t, PunchSet, parsed.Audits()[0].Mode)
+	}
+
+	assert.NotNil(t, parsed.Entry("a b@example.com", "sub project"))
+}
+
+func TestNoteApply(t *testing.T) {
+	start := time.Date(2015, 6, 1, 9, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		mode     PunchMode
+		existing *TimeEntry
+		entry    *TimeEntry
+		expected time.Duration
+		err      string
+	}{
+		{PunchSet, nil, &TimeEntry{Author: "a", Time: time.Hour}, time.Hour, ""},
+		{PunchSet, &TimeEntry{Author: "a", Time: time.Hour}, &TimeEntry{Author: "a", Time: time.Minute}, time.Minute, ""},
+		{PunchAdd, nil, &TimeEntry{Author: "a", Time: time.Hour}, time.Hour, ""},
+		{PunchAdd, &TimeEntry{Author: "a", Time: time.Hour}, &TimeEntry{Author: "a", Time: time.Minute}, 61 * time.Minute, ""},
+		{PunchSubtract, &TimeEntry{Author: "a", Time: time.Hour}, &TimeEntry{Author: "a", Time: time.Hour}, 0, ""},
+		{PunchSubtract, &TimeEntry{Author: "a", Time: time.Hour}, &TimeEntry{Author: "a", Time: 2 * time.Hour}, time.Hour, "Cannot subtract 2h0m0s from the 1h0m0s"},
+		{PunchSubtract, nil, &TimeEntry{Author: "a", Time: time.Minute}, 0, "has no time recorded to subtract from"},
+		{PunchSubtract, &TimeEntry{Author: "b", Time: time.Hour}, &TimeEntry{Author: "a", Time: time.Minute}, 0, "has no time recorded to subtract from"},
+		{PunchMode("multiply"), nil, &TimeEntry{Author: "a", Time: time.Minute}, 0, "Unknown punch mode"},
+	} {
+		n := NewNote()
+		if c.existing != nil {
+			n.Set(c.existing)
+		}
+
+		err := n.Apply(c.mode, c.entry, "by@example.com", "")
+		if c.err != "" {
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), c.err)
+			}
+
+			//a failed change is not audited
+			assert.Len(t, n.Audits(), 0)
+		} else {
+			assert.NoError(t, err)
+			assert.Len(t, n.Audits(), 1)
+		}
+
+		if e := n.Entry(c.entry.Author, ""); e != nil {
+			assert.Equal(t, c.expected, e.Time, "%s %+v", c.mode, c.entry)
+		} else {
+			assert.Equal(t, time.Duration(0), c.expected)
+		}
+	}
+
+	//adding widens the session and keeps the other fields
+	n := NewNote()
+	n.Set(&TimeEntry{Author: "a", Time: time.Hour, Start: start, End: start.Add(time.Hour)})
+	assert.NoError(t, n.Apply(PunchAdd, &TimeEntry{Author: "a", Time: time.Minute, OffHours: time.Minute, MBU: time.Minute, Start: start.Add(-time.Hour), End: start.Add(30 * time.Minute)}, "a", ""))
+	e := n.Entry("a", "")
+	assert.Equal(t, start.Add(-time.Hour), e.Start)
+	assert.Equal(t, start.Add(time.Hour), e.End)
+	assert.Equal(t, time.Minute, e.MBU)
+	assert.Equal(t, time.Minute, n.OffHours())
+
+	//entries of other authors and projects are untouched
+	assert.NoError(t, n.Apply(PunchAdd, &TimeEntry{Author: "a", Project: "sub", Time: time.Minute}, "a", ""))
+	assert.NoError(t, n.Apply(PunchSet, &TimeEntry{Author: "b", Time: time.Minute}, "b", ""))
+	assert.Equal(t, 61*time.Minute, n.Entry("a", "").Time)
+	assert.Equal(t, 63*time.Minute, n.Total())
+
+	//subtracting keeps the off-hours within the time
+	n = NewNote()
+	n.Set(&TimeEntry{Author: "a", Time: time.Hour, OffHours: 30 * time.Minute})
+	assert.NoError(t, n.Apply(PunchSubtract, &TimeEntry{Author: "a", Time: 40 * time.Minute}, "a", ""))
+	assert.Equal(t, 20*time.Minute, n.Entry("a", "").Time)
+	assert.Equal(t, 20*time.Minute, n.OffHours())
+}
+
+func TestNoteUpgrade(t *testing.T) {
+	n, err := ParseNote(strings.NewReader("total=45m"))
+	assert.NoError(t, err)
+	assert.True(t, n.Upgrade("alice@example.com"))
+	assert.Equal(t, NoteVersion, n.Version())
+	if assert.NotNil(t, n.Entry("alice@example.com", "")) {
+		assert.Equal(t, 45*time.Minute, n.Entry("alice@example.com", "").Time)
+	}
+
+	assert.False(t, n.Upgrade("bob@example.com"))
+	assert.Equal(t, 45*time.Minute, n.Total())
+	assert.Equal(t, "version=2\ntotal=45m0s\nentry author=alice@example.com time=45m0s\n", n.String())
+}
@@ -0,0 +1,536 @@
+package vcs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timeglass/glass/_vendor/github.com/hashicorp/errwrap"
+)
+
+// directories without version control keep their checkpoints, time
+// data and estimates in an append-only ledger with a json record
+// per line, later records for the same checkpoint or estimate win
+var PlainDir = ".timeglass"
+var PlainLedger = "ledger.jsonl"
+
+// date formats that are accepted by --since and --until
+var plainDateFormats = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+type ledgerRecord struct {
+	Kind       string    `json:"kind"`
+	ID         string    `json:"id,omitempty"`
+	Date       time.Time `json:"date"`
+	Author     string    `json:"author,omitempty"`
+	AuthorName string    `json:"author_name,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	Estimate   string    `json:"estimate,omitempty"`
+}
+
+// Plain tracks time in any directory, it has no history of its own:
+// instead "commits" are checkpoints that are created explicitly
+type Plain struct {
+	root string
+	init string
+}
+
+func NewPlain(dir string) *Plain {
+	return &Plain{
+		root: dir,
+		init: dir,
+	}
+}
+
+func (p *Plain) Name() string { return "plain" }
+
+// IsAvailable is always true, the root is the closest
+// directory that already has a ledger or else the
+// directory the instance was created for
+func (p *Plain) IsAvailable() bool {
+	p.root = p.init
+	for dir := p.init; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, PlainDir, PlainLedger)); err == nil {
+			p.root = dir
+			break
+		}
+
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	return true
+}
+
+func (p *Plain) Root() string {
+	return p.root
+}
+
+func (p *Plain) ledgerPath() string {
+	return filepath.Join(p.root, PlainDir, PlainLedger)
+}
+
+// there is nothing to hook into, checkpoints are created by hand
+func (p *Plain) Hook() ([]*Change, error) { return []*Change{}, nil }
+
+func (p *Plain) Unhook(dry bool) ([]*Change, error) { return []*Change{}, nil }
+
+func (p *Plain) DefaultRemote() (string, error) { return "", ErrNoRemote }
+
+func (p *Plain) Push(remote string, refs string) error { return ErrNoRemote }
+
+func (p *Plain) Pull(remote string) error { return ErrNoRemote }
+
+func (p *Plain) CurrentBranch() (string, error) { return "", nil }
+
+func (p *Plain) BranchCommits(branch string) ([]string, error) { return []string{}, nil }
+
+// the email address of the current user, taken from the EMAIL
+// environment variable or the global git configuration if git
+// is installed and otherwise made up from the user and host name
+func (p *Plain) author() (string, string) {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); name == "" && err == nil {
+		name = u.Username
+	}
+
+	if out, err := exec.Command("git", "config", "--global", "user.name").Output(); err == nil && len(strings.TrimSpace(string(out))) > 0 {
+		name = strings.TrimSpace(string(out))
+	}
+
+	if email := os.Getenv("EMAIL"); email != "" {
+		return email, name
+	}
+
+	if out, err := exec.Command("git", "config", "--global", "user.email").Output(); err == nil && len(strings.TrimSpace(string(out))) > 0 {
+		return strings.TrimSpace(string(out)), name
+	}
+
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s@%s", name, host), name
+}
+
+func (p *Plain) read() ([]*ledgerRecord, error) {
+	records := []*ledgerRecord{}
+	f, err := os.Open(p.ledgerPath())
+	if os.IsNotExist(err) {
+		return records, nil
+	} else if err != nil {
+		return nil, errwrap.Wrapf("Failed to open ledger: {{err}}", err)
+	}
+
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		rec := &ledgerRecord{}
+		err = json.Unmarshal(scanner.Bytes(), rec)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("Failed to parse line %d of ledger '%s': {{err}}", n, p.ledgerPath()), err)
+		}
+
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errwrap.Wrapf("Failed to read ledger: {{err}}", err)
+	}
+
+	return records, nil
+}
+
+// appends the records to the ledger with a single write
+func (p *Plain) append(recs ...*ledgerRecord) error {
+	err := os.MkdirAll(filepath.Join(p.root, PlainDir), 0755)
+	if err != nil {
+		return errwrap.Wrapf("Failed to create ledger directory: {{err}}", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	for _, rec := range recs {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return errwrap.Wrapf("Failed to encode ledger record: {{err}}", err)
+		}
+
+		buf.Write(append(data, '\n'))
+	}
+
+	f, err := os.OpenFile(p.ledgerPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return errwrap.Wrapf("Failed to open ledger: {{err}}", err)
+	}
+
+	defer f.Close()
+	_, err = f.Write(buf.Bytes())
+	if err != nil {
+		return errwrap.Wrapf("Failed to write to ledger: {{err}}", err)
+	}
+
+	return nil
+}
+
+// returns the checkpoints oldest first and the latest note of each
+func (p *Plain) checkpoints() ([]*Commit, map[string]string, error) {
+	records, err := p.read()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	commits := []*Commit{}
+	notes := map[string]string{}
+	for _, rec := range records {
+		switch rec.Kind {
+		case "checkpoint":
+			subject, body := rec.Message, ""
+			if parts := strings.SplitN(rec.Message, "\n", 2); len(parts) == 2 {
+				subject, body = parts[0], strings.TrimSpace(parts[1])
+			}
+
+			commits = append(commits, &Commit{
+				Hash:       rec.ID,
+				Date:       rec.Date,
+				Author:     rec.Author,
+				AuthorName: rec.AuthorName,
+				Subject:    subject,
+				Body:       body,
+			})
+		case "note":
+			notes[rec.ID] = rec.Note
+		}
+	}
+
+	return commits, notes, nil
+}
+
+// Checkpoint records a new checkpoint with the given message, it
+// becomes HEAD and returns its id which is unique like a commit hash.
+// The time of the entry (if any) is written along with the checkpoint
+// such that neither is recorded without the other
+func (p *Plain) Checkpoint(message string, entry *TimeEntry) (string, error) {
+	if strings.TrimSpace(message) == "" {
+		return "", fmt.Errorf("A checkpoint needs a message")
+	}
+
+	author, name := p.author()
+	rec := &ledgerRecord{Kind: "checkpoint", Date: time.Now(), Author: author, AuthorName: name, Message: message}
+	rec.ID = fmt.Sprintf("%x", sha1.Sum([]byte(fmt.Sprintf("%s\x00%s\x00%s", rec.Date.Format(time.RFC3339Nano), author, message))))
+	if entry == nil {
+		return rec.ID, p.append(rec)
+	}
+
+	if entry.Author == "" {
+		entry.Author = author
+	}
+
+	note := NewNote()
+	err := note.Apply(PunchSet, entry, author, "")
+	if err != nil {
+		return "", err
+	}
+
+	return rec.ID, p.append(rec, &ledgerRecord{Kind: "note", ID: rec.ID, Date: rec.Date, Note: note.String()})
+}
+
+// resolves a revision to the index of a checkpoint, understood are
+// HEAD, HEAD~n and (abbreviated) ids
+func (p *Plain) resolve(commits []*Commit, rev string) (int, error) {
+	if rev == "" || rev == "HEAD" || strings.HasPrefix(rev, "HEAD~") {
+		back := 0
+		if strings.HasPrefix(rev, "HEAD~") {
+			n, err := strconv.Atoi(rev[len("HEAD~"):])
+			if err != nil {
+				return 0, fmt.Errorf("Unknown revision '%s'", rev)
+			}
+
+			back = n
+		}
+
+		if len(commits) == 0 {
+			return 0, fmt.Errorf("No checkpoints yet, create one with 'glass checkpoint -m <message>'")
+		}
+
+		if back < 0 || back >= len(commits) {
+			return 0, fmt.Errorf("Unknown revision '%s', there are only %d checkpoints", rev, len(commits))
+		}
+
+		return len(commits) - 1 - back, nil
+	}
+
+	found := -1
+	for i, c := range commits {
+		if strings.HasPrefix(c.Hash, rev) {
+			if found >= 0 {
+				return 0, fmt.Errorf("Revision '%s' is ambiguous", rev)
+			}
+
+			found = i
+		}
+	}
+
+	if found < 0 {
+		return 0, fmt.Errorf("Unknown revision '%s'", rev)
+	}
+
+	return found, nil
+}
+
+func (p *Plain) Show(commit string) (TimeData, error) {
+	commits, notes, err := p.checkpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := p.resolve(commits, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	content, ok := notes[commits[i].Hash]
+	if !ok {
+		return NewNote(), ErrNoCommitTimeData
+	}
+
+	note, err := ParseNote(strings.NewReader(content))
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("Failed to parse note for checkpoint '%s': {{err}}", commit), err)
+	}
+
+	return note, nil
+}
+
+func (p *Plain) ShowAll(commits []string) (map[string]TimeData, error) {
+	_, notes, err := p.checkpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]TimeData{}
+	for _, c := range commits {
+		content, ok := notes[c]
+		if !ok {
+			continue
+		}
+
+		note, err := ParseNote(strings.NewReader(content))
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("Failed to parse note for checkpoint '%s': {{err}}", c), err)
+		}
+
+		res[c] = note
+	}
+
+	return res, nil
+}
+
+// Persist changes the entry of an author in the time data of a
+// checkpoint, the new version of the note is appended to the ledger
+func (p *Plain) Persist(commit string, mode PunchMode, entry *TimeEntry, message string) error {
+	by, _ := p.author()
+	if entry.Author == "" {
+		entry.Author = by
+	}
+
+	commits, notes, err := p.checkpoints()
+	if err != nil {
+		return err
+	}
+
+	i, err := p.resolve(commits, commit)
+	if err != nil {
+		return err
+	}
+
+	note := NewNote()
+	if content, ok := notes[commits[i].Hash]; ok {
+		note, err = ParseNote(strings.NewReader(content))
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("Failed to parse note for checkpoint '%s': {{err}}", commit), err)
+		}
+	}
+
+	err = note.Apply(mode, entry, by, message)
+	if err != nil {
+		return err
+	}
+
+	return p.append(&ledgerRecord{Kind: "note", ID: commits[i].Hash, Date: time.Now(), Note: note.String()})
+}
+
+// notes in the ledger are always written in the current format
+func (p *Plain) MigrateNotes() (int, error) { return 0, nil }
+
+func parsePlainDate(s string) (time.Time, error) {
+	for _, layout := range plainDateFormats {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Unsupported date '%s', expected a format like 2006-01-02 or 2006-01-02 15:04", s)
+}
+
+// Log lists the checkpoints that are selected by the query, newest first.
+// Checkpoints form a single line of history, a range "a..b" selects the
+// checkpoints after a up to and including b
+func (p *Plain) Log(q *Query) ([]*Commit, error) {
+	if len(q.Paths) > 0 {
+		return nil, fmt.Errorf("Checkpoints are not related to paths, select them by revision or date instead")
+	}
+
+	commits, _, err := p.checkpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	//the lowest and highest index that can be selected
+	low, high := 0, len(commits)-1
+	selected := map[int]bool{}
+	for _, rev := range q.Revs {
+		if strings.HasPrefix(rev, "^") {
+			i, err := p.resolve(commits, rev[1:])
+			if err != nil {
+				return nil, err
+			}
+
+			if i+1 > low {
+				low = i + 1
+			}
+
+			continue
+		}
+
+		if parts := strings.SplitN(rev, "..", 2); len(parts) == 2 {
+			from, err := p.resolve(commits, parts[0])
+			if err != nil {
+				return nil, err
+			}
+
+			to, err := p.resolve(commits, parts[1])
+			if err != nil {
+				return nil, err
+			}
+
+			for i := from + 1; i <= to; i++ {
+				selected[i] = true
+			}
+
+			continue
+		}
+
+		i, err := p.resolve(commits, rev)
+		if err != nil {
+			return nil, err
+		}
+
+		if q.NoWalk {
+			selected[i] = true
+		} else {
+			for j := 0; j <= i; j++ {
+				selected[j] = true
+			}
+		}
+	}
+
+	//without revisions everything up to HEAD is selected
+	if len(selected) == 0 && !hasPositive(q.Revs) {
+		for i := range commits {
+			selected[i] = true
+		}
+	}
+
+	var since, until time.Time
+	if q.Since != "" {
+		if since, err = parsePlainDate(q.Since); err != nil {
+			return nil, err
+		}
+	}
+
+	if q.Until != "" {
+		if until, err = parsePlainDate(q.Until); err != nil {
+			return nil, err
+		}
+	}
+
+	res := []*Commit{}
+	for i := high; i >= low; i-- {
+		c := commits[i]
+		switch {
+		case !selected[i]:
+		case !since.IsZero() && c.Date.Before(since):
+		case !until.IsZero() && c.Date.After(until):
+		case q.Author != "" && !strings.Contains(c.Author, q.Author) && !strings.Contains(c.AuthorName, q.Author):
+		case q.Grep != "" && !strings.Contains(c.Subject+"\n"+c.Body, q.Grep):
+		default:
+			res = append(res, c)
+		}
+	}
+
+	return res, nil
+}
+
+// whether any of the revisions selects checkpoints instead of excluding them
+func hasPositive(revs []string) bool {
+	for _, rev := range revs {
+		if !strings.HasPrefix(rev, "^") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Plain) Estimates() (map[string]*Estimate, error) {
+	records, err := p.read()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{}
+	for _, rec := range records {
+		if rec.Kind == "estimate" {
+			lines = append(lines, ESTIMATE_PREFIX+rec.Estimate)
+		}
+	}
+
+	return ParseEstimates(strings.NewReader(strings.Join(lines, "\n")))
+}
+
+func (p *Plain) SetEstimate(e *Estimate) error {
+	e.By, _ = p.author()
+	e.At = time.Now()
+	return p.append(&ledgerRecord{Kind: "estimate", Date: e.At, Estimate: e.String()})
+}
+
+// Cleanup removes the ledger with all checkpoints, time data and
+// estimates but only when 'data' is set as it has no other copy
+func (p *Plain) Cleanup(dry, data bool) ([]*Change, error) {
+	changes := []*Change{}
+	dir := filepath.Join(p.root, PlainDir)
+	if _, err := os.Stat(dir); err != nil || !data {
+		return changes, nil
+	}
+
+	changes = append(changes, &Change{dir, "delete"})
+	if !dry {
+		err := os.RemoveAll(dir)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("Failed to remove '%s': {{err}}", dir), err)
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,152 @@
+package vcs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlainCheckpoints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_plain")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := NewPlain(dir)
+	assert.True(t, p.IsAvailable())
+
+	_, err = p.Show("HEAD")
+	assert.Error(t, err)
+
+	ids := []string{}
+	for _, msg := range []string{"first draft", "second draft\n\nfixes PROJ-1", "final"} {
+		id, err := p.Checkpoint(msg, nil)
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	assert.NoError(t, p.Persist(ids[0], PunchSet, &TimeEntry{Time: 10 * time.Minute}, ""))
+	assert.NoError(t, p.Persist("HEAD", PunchSet, &TimeEntry{Time: time.Hour}, ""))
+	assert.NoError(t, p.Persist("HEAD", PunchAdd, &TimeEntry{Time: time.Minute}, ""))
+
+	data, err := p.Show(ids[2][:8])
+	assert.NoError(t, err)
+	assert.Equal(t, 61*time.Minute, data.Total())
+
+	_, err = p.Show("HEAD~1")
+	assert.Equal(t, ErrNoCommitTimeData, err)
+
+	all, err := p.ShowAll(ids)
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	//a sub directory uses the ledger of its parent
+	sub := filepath.Join(dir, "assets")
+	assert.NoError(t, os.Mkdir(sub, 0755))
+	p = NewPlain(sub)
+	assert.True(t, p.IsAvailable())
+	assert.Equal(t, dir, p.Root())
+
+	log, err := p.Log(&Query{})
+	assert.NoError(t, err)
+	assert.Len(t, log, 3)
+	assert.Equal(t, ids[2], log[0].Hash)
+	assert.Equal(t, "second draft", log[1].Subject)
+	assert.Equal(t, "fixes PROJ-1", log[1].Body)
+
+	log, err = p.Log(&Query{Revs: []string{ids[0] + "..HEAD"}})
+	assert.NoError(t, err)
+	assert.Len(t, log, 2)
+
+	log, err = p.Log(&Query{Revs: []string{"HEAD~1"}, Grep: "PROJ-1"})
+	assert.NoError(t, err)
+	assert.Len(t, log, 1)
+
+	log, err = p.Log(&Query{Revs: []string{ids[0], ids[2]}, NoWalk: true})
+	assert.NoError(t, err)
+	assert.Len(t, log, 2)
+
+	//time registered with a checkpoint is written along with it
+	_, err = p.Checkpoint("with time", &TimeEntry{Time: 25 * time.Minute})
+	assert.NoError(t, err)
+	data, err = p.Show("HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, 25*time.Minute, data.Total())
+	assert.NotEqual(t, "", data.Entries()[0].Author)
+}
+
+func TestPlainEstimates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_plain")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := NewPlain(dir)
+	assert.True(t, p.IsAvailable())
+
+	assert.NoError(t, p.SetEstimate(&Estimate{Key: IssueKey("PROJ-1"), Budget: time.Hour}))
+	assert.NoError(t, p.SetEstimate(&Estimate{Key: IssueKey("PROJ-1"), Budget: 2 * time.Hour}))
+
+	estimates, err := p.Estimates()
+	assert.NoError(t, err)
+	assert.Len(t, estimates, 1)
+	assert.Equal(t, 2*time.Hour, estimates[IssueKey("PROJ-1")].Budget)
+}
+
+func TestPlainProjects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_plain")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := NewPlain(dir)
+	assert.True(t, p.IsAvailable())
+	_, err = p.Checkpoint("services", nil)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(p.ledgerPath(), dir))
+
+	//each sub-project has an entry of its own
+	assert.NoError(t, p.Persist("HEAD", PunchSet, &TimeEntry{Author: "a@b.c", Time: time.Hour}, ""))
+	assert.NoError(t, p.Persist("HEAD", PunchSet, &TimeEntry{Author: "a@b.c", Project: "services/billing api", Time: 20 * time.Minute}, ""))
+	assert.NoError(t, p.Persist("HEAD", PunchAdd, &TimeEntry{Author: "a@b.c", Project: "services/billing api", Time: 10 * time.Minute}, ""))
+
+	data, err := p.Show("HEAD")
+	assert.NoError(t, err)
+	assert.Len(t, data.Entries(), 2)
+	assert.Equal(t, 90*time.Minute, data.Total())
+	assert.Equal(t, time.Hour, data.(*Note).Entry("a@b.c", "").Time)
+	assert.Equal(t, 30*time.Minute, data.(*Note).Entry("a@b.c", "services/billing api").Time)
+
+	parsed, err := ParseNote(strings.NewReader(data.(*Note).String()))
+	assert.NoError(t, err)
+	assert.Equal(t, "services/billing api", parsed.Entries()[1].Project)
+	assert.Equal(t, "services/billing api", parsed.Audits()[2].Project)
+}
+
+func TestPlainOffHours(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glass_plain")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := NewPlain(dir)
+	assert.True(t, p.IsAvailable())
+	_, err = p.Checkpoint("nightly", nil)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(p.ledgerPath(), dir))
+
+	//off-hours add up but are kept out of the total
+	assert.NoError(t, p.Persist("HEAD", PunchSet, &TimeEntry{Author: "a@b.c", Time: time.Hour, OffHours: 10 * time.Minute}, ""))
+	assert.NoError(t, p.Persist("HEAD", PunchAdd, &TimeEntry{Author: "a@b.c", OffHours: 5 * time.Minute}, ""))
+
+	data, err := p.Show("HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, data.Total())
+	assert.Equal(t, 15*time.Minute, data.OffHours())
+
+	parsed, err := ParseNote(strings.NewReader(data.(*Note).String()))
+	assert.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, parsed.Entries()[0].OffHours)
+	assert.Equal(t, time.Hour, parsed.Total())
+}
@@ -0,0 +1,53 @@
+package vcs
+
+import (
+	"strings"
+	"time"
+)
+
+// the key of the commit message trailer that holds the time
+// spent on a commit when no other key is configured
+var DefaultTrailer = "Time-Spent"
+
+// FormatTrailer returns a commit message trailer that records the
+// given time, zero units are left out for readability, e.g:
+// 'Time-Spent: 1h20m' instead of 'Time-Spent: 1h20m0s'
+func FormatTrailer(key string, d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+
+	return key + ": " + s
+}
+
+// ParseTrailers finds the time recorded in the trailers of a commit
+// message, these are read from its last paragraph. Trailers of
+// squashed commits end up in the same paragraph and add up
+func ParseTrailers(message, key string) (time.Duration, bool) {
+	paragraphs := strings.Split(strings.TrimSpace(strings.Replace(message, "\r\n", "\n", -1)), "\n\n")
+	last := paragraphs[len(paragraphs)-1]
+
+	var total time.Duration
+	found := false
+	for _, line := range strings.Split(last, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || !strings.EqualFold(strings.TrimSpace(parts[0]), key) {
+			continue
+		}
+
+		d, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			continue
+		}
+
+		total += d
+		found = true
+	}
+
+	return total, found
+}
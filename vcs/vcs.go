@@ -16,21 +16,84 @@ type VCS interface {
 	Name() string
 	Root() string
 	IsAvailable() bool
-	Hook() error
+	Hook() ([]*Change, error)
+	Unhook(bool) ([]*Change, error)
+	Cleanup(bool, bool) ([]*Change, error)
 	Push(string, string) error
 	Pull(string) error
 	DefaultRemote() (string, error)
-	Persist(time.Duration) error
+	Persist(string, PunchMode, *TimeEntry, string) error
 	Show(string) (TimeData, error)
+	ShowAll([]string) (map[string]TimeData, error)
+	MigrateNotes() (int, error)
+	Log(*Query) ([]*Commit, error)
+	Estimates() (map[string]*Estimate, error)
+	SetEstimate(*Estimate) error
+	CurrentBranch() (string, error)
+	BranchCommits(string) ([]string, error)
+}
+
+// Query selects commits from the history, filters
+// are passed to the VCS as-is such that users can
+// use the formats they are familiar with
+type Query struct {
+	Revs   []string
+	Since  string
+	Until  string
+	Author string
+	Paths  []string
+	All    bool
+
+	//only select commits with a message that
+	//contains this text, e.g: an issue key
+	Grep string
+
+	//only select the given revisions instead
+	//of all revisions that are reachable from them
+	NoWalk bool
+
+	//name the branch each commit can be reached from,
+	//this is costly for long histories so it is opt-in
+	Branches bool
+}
+
+// Commit describes a single revision in the history
+// of the repository, it is used to report on time data
+type Commit struct {
+	Hash       string
+	Date       time.Time
+	Author     string
+	AuthorName string
+	Subject    string
+	Body       string
+	Branch     string
+}
+
+// Change describes what was (or would be) done to a hook,
+// configuration or the time data of a repository
+type Change struct {
+	Path   string
+	Action string
+}
+
+func (c *Change) String() string {
+	return fmt.Sprintf("%s: %s", c.Path, c.Action)
 }
 
 type TimeData interface {
+	Version() int
 	Total() time.Duration
+	OffHours() time.Duration
+	Entries() []*TimeEntry
 }
 
 func GetVCS(dir string) (VCS, error) {
 	var supported = []VCS{
 		NewGit(dir),
+		NewHg(dir),
+
+		//any directory can be tracked without version control
+		NewPlain(dir),
 	}
 
 	var checked = []string{}